@@ -0,0 +1,51 @@
+package fileserver
+
+import (
+	"net"
+	"sort"
+)
+
+// InterfaceAddress describes an IPv4 address of a local network interface the server can bind to.
+type InterfaceAddress struct {
+	Name string
+	IP   string
+}
+
+func (a InterfaceAddress) String() string {
+	return a.IP + " (" + a.Name + ")"
+}
+
+// ListInterfaceAddresses returns the IPv4 addresses of all active, non-loopback interfaces.
+func ListInterfaceAddresses() []InterfaceAddress {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	var result []InterfaceAddress
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ipv4 := ipNet.IP.To4()
+			if ipv4 == nil || ipv4.IsLinkLocalUnicast() {
+				continue
+			}
+			result = append(result, InterfaceAddress{Name: iface.Name, IP: ipv4.String()})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].IP < result[j].IP
+	})
+	return result
+}
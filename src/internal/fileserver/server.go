@@ -0,0 +1,235 @@
+package fileserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tokenBytes        = 16
+	defaultTokenTTL   = 2 * time.Hour
+	readHeaderTimeout = 30 * time.Second
+)
+
+// Server is a small HTTP file server running on the station. Files are only reachable through
+// unguessable per-download tokens handed out by Publish, and only from the device a token was issued for.
+type Server struct {
+	bindIP   string
+	port     int
+	listener net.Listener
+	srv      *http.Server
+
+	mu     sync.Mutex
+	tokens map[string]publishedFile
+}
+
+type publishedFile struct {
+	path    string
+	name    string
+	device  []net.IP
+	expires time.Time
+}
+
+// Start listens on bindIP:port. An empty bindIP listens on all interfaces and a port of 0 picks a free port.
+// onError, if set, receives the error that stops the server unexpectedly; it is called from the server
+// goroutine.
+func Start(bindIP string, port int, onError func(error)) (*Server, error) {
+	bindIP = strings.TrimSpace(bindIP)
+	if bindIP != "" && net.ParseIP(bindIP) == nil {
+		return nil, fmt.Errorf("invalid bind address: %s", bindIP)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(bindIP, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	s := &Server{
+		bindIP:   bindIP,
+		port:     listener.Addr().(*net.TCPAddr).Port,
+		listener: listener,
+		tokens:   make(map[string]publishedFile),
+	}
+	s.srv = &http.Server{
+		Handler:           http.HandlerFunc(s.serveHTTP),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) && onError != nil {
+			onError(fmt.Errorf("file server stopped: %w", err))
+		}
+	}()
+
+	return s, nil
+}
+
+// BindIP returns the address the server was started with; an empty string means all interfaces.
+func (s *Server) BindIP() string {
+	return s.bindIP
+}
+
+// Port returns the TCP port the server listens on.
+func (s *Server) Port() int {
+	return s.port
+}
+
+// Publish makes localPath downloadable for the device at deviceIP and returns the URL together with a
+// release function that revokes the token again.
+func (s *Server) Publish(localPath, deviceIP string) (string, func(), error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("stat published file: %w", err)
+	}
+	if info.IsDir() {
+		return "", nil, fmt.Errorf("cannot publish directory: %s", localPath)
+	}
+
+	device, err := resolveDevice(deviceIP)
+	if err != nil {
+		return "", nil, err
+	}
+	host, err := s.hostFor(deviceIP)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := filepath.Base(localPath)
+	s.mu.Lock()
+	s.pruneExpiredLocked()
+	s.tokens[token] = publishedFile{path: localPath, name: name, device: device, expires: time.Now().Add(defaultTokenTTL)}
+	s.mu.Unlock()
+
+	release := func() {
+		s.mu.Lock()
+		delete(s.tokens, token)
+		s.mu.Unlock()
+	}
+
+	u := url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(host, strconv.Itoa(s.port)),
+		Path:   "/" + token + "/" + name,
+	}
+	return u.String(), release, nil
+}
+
+// Close stops the server and revokes all outstanding tokens.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.tokens = make(map[string]publishedFile)
+	s.mu.Unlock()
+	return s.srv.Close()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	entry, ok := s.tokens[parts[0]]
+	s.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) || parts[1] != entry.name || !entry.allows(r.RemoteAddr) {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := os.Open(entry.path)
+	if err != nil {
+		http.Error(w, "file unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "file unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, entry.name, info.ModTime(), file)
+}
+
+// allows reports whether remoteAddr, the address of a request, belongs to the device the file was
+// published for.
+func (f publishedFile) allows(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, device := range f.device {
+		if device.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDevice returns the addresses of deviceIP, which may also be a host name.
+func resolveDevice(deviceIP string) ([]net.IP, error) {
+	if ip := net.ParseIP(deviceIP); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	ips, err := net.LookupIP(deviceIP)
+	if err != nil {
+		return nil, fmt.Errorf("resolve device address %s: %w", deviceIP, err)
+	}
+	return ips, nil
+}
+
+// hostFor returns the station address the device should use to reach the server.
+func (s *Server) hostFor(deviceIP string) (string, error) {
+	if s.bindIP != "" && !net.ParseIP(s.bindIP).IsUnspecified() {
+		return s.bindIP, nil
+	}
+
+	// A UDP "connection" sends no packets but lets the OS pick the outgoing interface for deviceIP.
+	conn, err := net.Dial("udp", net.JoinHostPort(deviceIP, "9"))
+	if err != nil {
+		return "", fmt.Errorf("determine station address for %s: %w", deviceIP, err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+func (s *Server) pruneExpiredLocked() {
+	now := time.Now()
+	for token, entry := range s.tokens {
+		if now.After(entry.expires) {
+			delete(s.tokens, token)
+		}
+	}
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate download token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
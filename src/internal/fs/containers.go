@@ -0,0 +1,94 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContainerConfig is one container definition as stored in the env config file.
+// Command holds the docker flags in the encoded multiline format of CONTAINER_COMMAND.
+type ContainerConfig struct {
+	Name        string
+	Image       string
+	Command     string
+	StartOrder  int
+	WaitHealthy bool
+	Digest      string
+}
+
+const (
+	containerFieldName    = "NAME"
+	containerFieldImage   = "IMAGE"
+	containerFieldCommand = "COMMAND"
+	containerFieldOrder   = "ORDER"
+	containerFieldHealthy = "WAIT_HEALTHY"
+	containerFieldDigest  = "DIGEST"
+)
+
+// ContainerKey returns the config key of a field of the container at the given 1-based index.
+func ContainerKey(index int, field string) string {
+	return fmt.Sprintf("CONTAINER_%d_%s", index, field)
+}
+
+// LoadContainers reads all container definitions. Configs written before multiple containers were
+// supported only contain CONTAINER_IMAGE and CONTAINER_COMMAND and are returned as a single container.
+func LoadContainers(cfg EnvConfig) []ContainerConfig {
+	countRaw := strings.TrimSpace(cfg[ContainerCount])
+	if countRaw == "" {
+		if strings.TrimSpace(cfg[ContainerImage]) == "" && strings.TrimSpace(cfg[ContainerCommand]) == "" {
+			return nil
+		}
+		return []ContainerConfig{{
+			Image:      strings.TrimSpace(cfg[ContainerImage]),
+			Command:    cfg[ContainerCommand],
+			StartOrder: 1,
+		}}
+	}
+
+	count, err := strconv.Atoi(countRaw)
+	if err != nil || count < 0 {
+		return nil
+	}
+
+	containers := make([]ContainerConfig, 0, count)
+	for i := 1; i <= count; i++ {
+		order, err := strconv.Atoi(strings.TrimSpace(cfg[ContainerKey(i, containerFieldOrder)]))
+		if err != nil {
+			order = i
+		}
+		containers = append(containers, ContainerConfig{
+			Name:        strings.TrimSpace(cfg[ContainerKey(i, containerFieldName)]),
+			Image:       strings.TrimSpace(cfg[ContainerKey(i, containerFieldImage)]),
+			Command:     cfg[ContainerKey(i, containerFieldCommand)],
+			StartOrder:  order,
+			WaitHealthy: cfg[ContainerKey(i, containerFieldHealthy)] == "true",
+			Digest:      strings.TrimSpace(cfg[ContainerKey(i, containerFieldDigest)]),
+		})
+	}
+	return containers
+}
+
+// StoreContainers replaces all container definitions in cfg, including the legacy single container keys.
+func StoreContainers(cfg EnvConfig, containers []ContainerConfig) {
+	if oldCount, err := strconv.Atoi(strings.TrimSpace(cfg[ContainerCount])); err == nil {
+		for i := 1; i <= oldCount; i++ {
+			for _, field := range []string{containerFieldName, containerFieldImage, containerFieldCommand, containerFieldOrder, containerFieldHealthy, containerFieldDigest} {
+				delete(cfg, ContainerKey(i, field))
+			}
+		}
+	}
+	delete(cfg, ContainerImage)
+	delete(cfg, ContainerCommand)
+
+	cfg[ContainerCount] = strconv.Itoa(len(containers))
+	for i, container := range containers {
+		index := i + 1
+		cfg[ContainerKey(index, containerFieldName)] = container.Name
+		cfg[ContainerKey(index, containerFieldImage)] = container.Image
+		cfg[ContainerKey(index, containerFieldCommand)] = container.Command
+		cfg[ContainerKey(index, containerFieldOrder)] = strconv.Itoa(container.StartOrder)
+		cfg[ContainerKey(index, containerFieldHealthy)] = strconv.FormatBool(container.WaitHealthy)
+		cfg[ContainerKey(index, containerFieldDigest)] = container.Digest
+	}
+}
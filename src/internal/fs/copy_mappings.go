@@ -0,0 +1,105 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCopyRemotePath is the target of configs written before copy mappings were supported.
+const DefaultCopyRemotePath = "/root"
+
+// CopyMapping is one local→remote copy as stored in the env config file. Modes are octal strings,
+// empty values keep the defaults. PostCommand is stored in the encoded multiline format. GitRef selects
+// the tag, branch or commit to copy when Local is a git repository.
+type CopyMapping struct {
+	Local       string
+	GitRef      string
+	Remote      string
+	Owner       string
+	Group       string
+	FileMode    string
+	DirMode     string
+	PostCommand string
+}
+
+const (
+	copyMappingFieldLocal    = "LOCAL"
+	copyMappingFieldRemote   = "REMOTE"
+	copyMappingFieldOwner    = "OWNER"
+	copyMappingFieldGroup    = "GROUP"
+	copyMappingFieldFileMode = "FILE_MODE"
+	copyMappingFieldDirMode  = "DIR_MODE"
+	copyMappingFieldPost     = "POST_COMMAND"
+	copyMappingFieldGitRef   = "GIT_REF"
+)
+
+var copyMappingFields = []string{
+	copyMappingFieldLocal, copyMappingFieldRemote, copyMappingFieldOwner, copyMappingFieldGroup,
+	copyMappingFieldFileMode, copyMappingFieldDirMode, copyMappingFieldPost, copyMappingFieldGitRef,
+}
+
+// CopyMappingKey returns the config key of a field of the copy mapping at the given 1-based index.
+func CopyMappingKey(index int, field string) string {
+	return fmt.Sprintf("COPY_MAPPING_%d_%s", index, field)
+}
+
+// LoadCopyMappings reads all copy mappings. Configs written before mappings were supported only contain
+// CONFIG_PATH, which is returned as a single mapping to /root.
+func LoadCopyMappings(cfg EnvConfig) []CopyMapping {
+	countRaw := strings.TrimSpace(cfg[CopyMappingCount])
+	if countRaw == "" {
+		if strings.TrimSpace(cfg[ConfigPath]) == "" {
+			return nil
+		}
+		return []CopyMapping{{
+			Local:  strings.TrimSpace(cfg[ConfigPath]),
+			Remote: DefaultCopyRemotePath,
+		}}
+	}
+
+	count, err := strconv.Atoi(countRaw)
+	if err != nil || count < 0 {
+		return nil
+	}
+
+	mappings := make([]CopyMapping, 0, count)
+	for i := 1; i <= count; i++ {
+		mappings = append(mappings, CopyMapping{
+			Local:       strings.TrimSpace(cfg[CopyMappingKey(i, copyMappingFieldLocal)]),
+			GitRef:      strings.TrimSpace(cfg[CopyMappingKey(i, copyMappingFieldGitRef)]),
+			Remote:      strings.TrimSpace(cfg[CopyMappingKey(i, copyMappingFieldRemote)]),
+			Owner:       strings.TrimSpace(cfg[CopyMappingKey(i, copyMappingFieldOwner)]),
+			Group:       strings.TrimSpace(cfg[CopyMappingKey(i, copyMappingFieldGroup)]),
+			FileMode:    strings.TrimSpace(cfg[CopyMappingKey(i, copyMappingFieldFileMode)]),
+			DirMode:     strings.TrimSpace(cfg[CopyMappingKey(i, copyMappingFieldDirMode)]),
+			PostCommand: cfg[CopyMappingKey(i, copyMappingFieldPost)],
+		})
+	}
+	return mappings
+}
+
+// StoreCopyMappings replaces all copy mappings in cfg, including the legacy CONFIG_PATH key.
+func StoreCopyMappings(cfg EnvConfig, mappings []CopyMapping) {
+	if oldCount, err := strconv.Atoi(strings.TrimSpace(cfg[CopyMappingCount])); err == nil {
+		for i := 1; i <= oldCount; i++ {
+			for _, field := range copyMappingFields {
+				delete(cfg, CopyMappingKey(i, field))
+			}
+		}
+	}
+	delete(cfg, ConfigPath)
+
+	cfg[CopyMappingCount] = strconv.Itoa(len(mappings))
+	for i, mapping := range mappings {
+		index := i + 1
+		cfg[CopyMappingKey(index, copyMappingFieldLocal)] = mapping.Local
+		cfg[CopyMappingKey(index, copyMappingFieldGitRef)] = mapping.GitRef
+		cfg[CopyMappingKey(index, copyMappingFieldRemote)] = mapping.Remote
+		cfg[CopyMappingKey(index, copyMappingFieldOwner)] = mapping.Owner
+		cfg[CopyMappingKey(index, copyMappingFieldGroup)] = mapping.Group
+		cfg[CopyMappingKey(index, copyMappingFieldFileMode)] = mapping.FileMode
+		cfg[CopyMappingKey(index, copyMappingFieldDirMode)] = mapping.DirMode
+		cfg[CopyMappingKey(index, copyMappingFieldPost)] = mapping.PostCommand
+	}
+}
@@ -1,15 +1,62 @@
 package fs
 
+import "strings"
+
 var (
 	AWSRegion           = "AWS_REGION"
 	AWSAccountID        = "AWS_ACCOUNT_ID"
 	AWSAccessID         = "AWS_ACCESS_ID"
 	AWSAccessKey        = "AWS_ACCESS_KEY"
+	RegistryType        = "REGISTRY_TYPE"
+	RegistryServer      = "REGISTRY_SERVER"
+	RegistryUsername    = "REGISTRY_USERNAME"
+	RegistryPassword    = "REGISTRY_PASSWORD"
 	ConfigPath          = "CONFIG_PATH"
 	ContainerImage      = "CONTAINER_IMAGE"
 	IpAddress           = "IP_ADDRESS"
 	ContainerCommand    = "CONTAINER_COMMAND"
+	ContainerCount      = "CONTAINER_COUNT"
+	ContainerMode       = "CONTAINER_MODE"
+	ComposeFile         = "COMPOSE_FILE"
+	ComposeEnvFile      = "COMPOSE_ENV_FILE"
+	ComposeProjectDir   = "COMPOSE_PROJECT_DIR"
+	ImageSource         = "IMAGE_SOURCE"
+	ImageTarball        = "IMAGE_TARBALL"
 	FirmwareRevision    = "FIRMWARE_REVISION"
 	FirmwarePath        = "FIRMWARE_PATH"
 	ForceFirmwareUpdate = "FORCE_FIRMWARE_UPDATE"
+	FirmwareHealthCheck = "FIRMWARE_HEALTH_CHECK"
+	HTTPServerEnabled   = "HTTP_SERVER_ENABLED"
+	HTTPServerInterface = "HTTP_SERVER_INTERFACE"
+	HTTPServerPort      = "HTTP_SERVER_PORT"
+	TransferSlots       = "TRANSFER_SLOTS"
+	DockerCleanup       = "DOCKER_CLEANUP"
+	DeviceVariables     = "DEVICE_VARIABLES"
+	ConfigTemplates     = "CONFIG_TEMPLATES"
+	CopySync            = "COPY_SYNC"
+	CopySyncDelete      = "COPY_SYNC_DELETE"
+	CopyDryRun          = "COPY_DRY_RUN"
+	CopyMappingCount    = "COPY_MAPPING_COUNT"
+	CopyExclude         = "COPY_EXCLUDE"
+	CopyCompress        = "COPY_COMPRESS"
+	CopyCompressLevel   = "COPY_COMPRESS_LEVEL"
+	CopyBackup          = "COPY_BACKUP"
+	DiagnosticsPaths    = "DIAGNOSTICS_PATHS"
 )
+
+// IsSecretKey reports whether the value stored under key is a credential that must not leave the config file.
+// Besides the known credential keys this covers names that look like credentials, such as custom
+// device variables called DB_PASSWORD or API_TOKEN.
+func IsSecretKey(key string) bool {
+	switch key {
+	case AWSAccessID, AWSAccessKey, RegistryPassword:
+		return true
+	}
+	upper := strings.ToUpper(key)
+	for _, marker := range []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN"} {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return strings.HasSuffix(upper, "_KEY")
+}
@@ -1,7 +1,9 @@
 package gui
 
 import (
+	"wago-init/internal/fileserver"
 	"wago-init/internal/fs"
+	"wago-init/internal/install"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -15,18 +17,22 @@ type mainView struct {
 	window               fyne.Window
 	configValues         fs.EnvConfig
 	ipEntry              *widget.Entry
-	configPathEntry      *widget.Entry
 	startBtn             *widget.Button
 	passwordPrompt       func() (string, bool)
 	newPasswordPrompt    func(*installSession) (string, bool)
 	containerSettingsBtn *widget.Button
-	awsSettingsBtn       *widget.Button
+	registrySettingsBtn  *widget.Button
 	firmwareSettingsBtn  *widget.Button
+	stationSettingsBtn   *widget.Button
+	variablesSettingsBtn *widget.Button
+	copySettingsBtn      *widget.Button
 	deviceDiscoveryBtn   *widget.Button
 	sessions             []*installSession
 	sessionsBox          *fyne.Container
 	sessionsScroll       *container.Scroll
 	deviceDiscoveryCache []discoveredDevice
+	artifactServer       *fileserver.Server
+	transferScheduler    *install.TransferScheduler
 }
 
 func BuildMainWindow() {
@@ -53,6 +59,7 @@ func newMainView(application fyne.App, window fyne.Window, configValues fs.EnvCo
 		configValues:      configValues,
 		passwordPrompt:    passwordPromtFunc(window),
 		newPasswordPrompt: newPasswordPromtFunc(window),
+		transferScheduler: install.NewTransferScheduler(transferSlotsFromConfig(configValues)),
 	}
 }
 
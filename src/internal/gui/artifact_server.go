@@ -0,0 +1,82 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wago-init/internal/fileserver"
+	"wago-init/internal/fs"
+	"wago-init/internal/install"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+const allInterfacesOption = "All interfaces"
+
+// ensureArtifactServer returns the station HTTP server if it is enabled in the settings. The server is
+// started on first use and restarted when the interface or port settings change.
+func (mv *mainView) ensureArtifactServer() (install.ArtifactServer, error) {
+	if strings.TrimSpace(mv.configValues[fs.HTTPServerEnabled]) != "true" {
+		return nil, nil
+	}
+
+	bindIP := strings.TrimSpace(mv.configValues[fs.HTTPServerInterface])
+	port := 0
+	if raw := strings.TrimSpace(mv.configValues[fs.HTTPServerPort]); raw != "" {
+		num, err := strconv.Atoi(raw)
+		if err != nil || num < 0 || num > 65535 {
+			return nil, fmt.Errorf("invalid HTTP server port '%s'", raw)
+		}
+		port = num
+	}
+
+	if mv.artifactServer != nil {
+		if mv.artifactServer.BindIP() == bindIP && (port == 0 || mv.artifactServer.Port() == port) {
+			return mv.artifactServer, nil
+		}
+		if mv.hasRunningSession() {
+			return nil, fmt.Errorf("HTTP server settings changed while installations are running; wait for them to finish")
+		}
+		_ = mv.artifactServer.Close()
+		mv.artifactServer = nil
+	}
+
+	var server *fileserver.Server
+	// The next installation starts a fresh server when this one stops unexpectedly; devices fall back
+	// to SSH uploads meanwhile.
+	onError := func(err error) {
+		mv.runOnUI(func() {
+			if mv.artifactServer == server {
+				mv.artifactServer = nil
+			}
+			dialog.ShowError(err, mv.window)
+		})
+	}
+	server, err := fileserver.Start(bindIP, port, onError)
+	if err != nil {
+		return nil, err
+	}
+	mv.artifactServer = server
+	return server, nil
+}
+
+func (mv *mainView) hasRunningSession() bool {
+	for _, session := range mv.sessions {
+		if !session.isFinished() {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceIP extracts the IP address from an interface select option.
+func interfaceIP(option string) string {
+	if option == "" || option == allInterfacesOption {
+		return ""
+	}
+	if idx := strings.Index(option, " "); idx > 0 {
+		return option[:idx]
+	}
+	return option
+}
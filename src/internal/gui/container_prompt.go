@@ -1,8 +1,12 @@
 package gui
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"wago-init/internal/fs"
+	"wago-init/internal/install"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -10,6 +14,13 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+var digestPattern = regexp.MustCompile(`^(sha256:)?[0-9a-f]{64}$`)
+
+const (
+	containerModeCreateLabel  = "Docker create flags"
+	containerModeComposeLabel = "Docker compose"
+)
+
 func BuildContainerPrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.Button {
 	containerBtn := widget.NewButton("Container settings", func() {
 		values := fs.EnvConfig{}
@@ -17,23 +28,289 @@ func BuildContainerPrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.But
 			values = *configValues
 		}
 
+		containers := fs.LoadContainers(values)
+		if len(containers) == 0 {
+			containers = []fs.ContainerConfig{{StartOrder: 1}}
+		}
+		selected := -1
+
+		nameEntry := widget.NewEntry()
+		nameEntry.SetPlaceHolder("e.g., app")
+
 		imageEntry := widget.NewEntry()
-		imageEntry.SetText(values[fs.ContainerImage])
+
+		orderEntry := widget.NewEntry()
+		orderEntryContainer := container.NewGridWrap(fyne.NewSize(65, orderEntry.MinSize().Height), orderEntry)
 
 		commandEntry := widget.NewMultiLineEntry()
-		commandEntry.SetText(fs.DecodeMultilineValue(values[fs.ContainerCommand]))
 		commandEntry.Wrapping = fyne.TextWrapWord
-		commandEntry.SetMinRowsVisible(24)
+		commandEntry.SetMinRowsVisible(20)
+
+		restartSelect := widget.NewSelectEntry([]string{"no", "always", "unless-stopped", "on-failure"})
+		restartSelect.SetPlaceHolder("Docker default (no)")
+		networkSelect := widget.NewSelectEntry([]string{"bridge", "host", "none"})
+		networkSelect.SetPlaceHolder("Docker default (bridge)")
+		privilegedCheck := widget.NewCheck("Privileged", nil)
+		portsEntry := newFlagListEntry("One per line, e.g., 8080:80/tcp")
+		volumesEntry := newFlagListEntry("One per line, e.g., /home/app:/data:ro")
+		envEntry := newFlagListEntry("One per line, e.g., LOG_LEVEL=info")
+		devicesEntry := newFlagListEntry("One per line, e.g., /dev/ttyUSB0")
+		extraEntry := newFlagListEntry("Other flags, one per line, e.g., --memory 256m")
+
+		// flagsName keeps a --name from the flags that could not be moved to the Name field.
+		flagsName := ""
+		// shownFlags are the flags last parsed into the settings; unchanged values keep their quoting.
+		var shownFlags install.ContainerFlags
+
+		structuredFlags := func() install.ContainerFlags {
+			return install.ContainerFlags{
+				Name:       flagsName,
+				Restart:    strings.TrimSpace(restartSelect.Text),
+				Ports:      nonEmptyLines(portsEntry.Text),
+				Volumes:    nonEmptyLines(volumesEntry.Text),
+				Env:        nonEmptyLines(envEntry.Text),
+				Devices:    nonEmptyLines(devicesEntry.Text),
+				Network:    strings.TrimSpace(networkSelect.Text),
+				Privileged: privilegedCheck.Checked,
+				Extra:      nonEmptyLines(extraEntry.Text),
+			}.KeepQuoting(shownFlags)
+		}
+
+		showStructured := func(flags install.ContainerFlags) {
+			shownFlags = flags
+			flagsName = flags.Name
+			restartSelect.SetText(flags.Restart)
+			networkSelect.SetText(flags.Network)
+			privilegedCheck.SetChecked(flags.Privileged)
+			portsEntry.SetText(strings.Join(flags.Ports, "\n"))
+			volumesEntry.SetText(strings.Join(flags.Volumes, "\n"))
+			envEntry.SetText(strings.Join(flags.Env, "\n"))
+			devicesEntry.SetText(strings.Join(flags.Devices, "\n"))
+			extraEntry.SetText(strings.Join(flags.Extra, "\n"))
+		}
+
+		structuredForm := widget.NewForm(
+			widget.NewFormItem("Restart policy", restartSelect),
+			widget.NewFormItem("Network mode", networkSelect),
+			widget.NewFormItem("", privilegedCheck),
+			widget.NewFormItem("Ports", portsEntry),
+			widget.NewFormItem("Volumes", volumesEntry),
+			widget.NewFormItem("Environment", envEntry),
+			widget.NewFormItem("Devices", devicesEntry),
+			widget.NewFormItem("Extra args", extraEntry),
+		)
+
+		structuredTab := container.NewTabItem("Settings", container.NewVScroll(structuredForm))
+		rawTab := container.NewTabItem("Raw flags", commandEntry)
+		flagTabs := container.NewAppTabs(structuredTab, rawTab)
+		// showRawUnchanged switches to the raw tab without overwriting it with the settings, which is
+		// needed when the raw flags cannot be parsed.
+		keepRaw := false
+		showRawUnchanged := func() {
+			keepRaw = true
+			flagTabs.Select(rawTab)
+			keepRaw = false
+		}
+		flagTabs.OnSelected = func(tab *container.TabItem) {
+			if tab == rawTab {
+				if !keepRaw {
+					commandEntry.SetText(structuredFlags().Format())
+				}
+				return
+			}
+			flags, err := install.ParseContainerFlags(commandEntry.Text)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("cannot edit flags as settings: %w", err), w)
+				showRawUnchanged()
+				return
+			}
+			showStructured(flags)
+		}
+
+		currentFlags := func() string {
+			if flagTabs.Selected() == structuredTab {
+				return structuredFlags().Format()
+			}
+			return strings.TrimSpace(commandEntry.Text)
+		}
+
+		digestEntry := widget.NewEntry()
+		digestEntry.SetPlaceHolder("Optional pinned digest, e.g., sha256:3f5a...")
+
+		waitHealthyCheck := widget.NewCheck("Wait until healthy (Docker HEALTHCHECK)", nil)
+
+		var list *widget.List
+
+		storeSelected := func() {
+			if selected < 0 || selected >= len(containers) {
+				return
+			}
+			order, err := strconv.Atoi(strings.TrimSpace(orderEntry.Text))
+			if err != nil {
+				order = containers[selected].StartOrder
+			}
+			containers[selected] = fs.ContainerConfig{
+				Name:        strings.TrimSpace(nameEntry.Text),
+				Image:       strings.TrimSpace(imageEntry.Text),
+				Command:     fs.EncodeMultilineValue(currentFlags()),
+				StartOrder:  order,
+				WaitHealthy: waitHealthyCheck.Checked,
+				Digest:      strings.TrimSpace(digestEntry.Text),
+			}
+		}
+
+		showSelected := func() {
+			current := containers[selected]
+			rawFlags := fs.DecodeMultilineValue(current.Command)
+			flags, err := install.ParseContainerFlags(rawFlags)
 
-		imageForm := widget.NewForm(widget.NewFormItem("Image", imageEntry))
+			// Older configs set the name in the flags; it belongs in the Name field now.
+			if err == nil && flags.Name != "" && current.Name == "" {
+				current.Name = flags.Name
+				flags.Name = ""
+				rawFlags = flags.Format()
+			}
+
+			nameEntry.SetText(current.Name)
+			imageEntry.SetText(current.Image)
+			orderEntry.SetText(strconv.Itoa(current.StartOrder))
+			if err != nil {
+				showRawUnchanged()
+			} else {
+				showStructured(flags)
+			}
+			commandEntry.SetText(rawFlags)
+			waitHealthyCheck.SetChecked(current.WaitHealthy)
+			digestEntry.SetText(current.Digest)
+		}
+
+		list = widget.NewList(
+			func() int {
+				return len(containers)
+			},
+			func() fyne.CanvasObject {
+				return widget.NewLabel("")
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				obj.(*widget.Label).SetText(containerListLabel(containers[id], id))
+			},
+		)
+		list.OnSelected = func(id widget.ListItemID) {
+			if id == selected {
+				return
+			}
+			storeSelected()
+			selected = id
+			showSelected()
+			list.Refresh()
+		}
+
+		addBtn := widget.NewButton("Add", func() {
+			storeSelected()
+			containers = append(containers, fs.ContainerConfig{StartOrder: nextStartOrder(containers)})
+			list.Refresh()
+			list.Select(len(containers) - 1)
+		})
+
+		removeBtn := widget.NewButton("Remove", func() {
+			if selected < 0 || len(containers) <= 1 {
+				return
+			}
+			containers = append(containers[:selected], containers[selected+1:]...)
+			selected = -1
+			list.UnselectAll()
+			list.Refresh()
+			list.Select(0)
+		})
+
+		listPane := container.NewBorder(nil, container.NewGridWithColumns(2, addBtn, removeBtn), nil, nil, list)
+
+		form := widget.NewForm(
+			widget.NewFormItem("Name", nameEntry),
+			widget.NewFormItem("Image", imageEntry),
+			widget.NewFormItem("Digest", digestEntry),
+			widget.NewFormItem("Start order", orderEntryContainer),
+			widget.NewFormItem("", waitHealthyCheck),
+		)
 		flagsLabel := widget.NewLabel("Container Flags")
 		flagsLabel.Alignment = fyne.TextAlignLeading
-		content := container.NewVBox(
-			imageForm,
-			flagsLabel,
-			commandEntry,
+		editPane := container.NewBorder(container.NewVBox(form, flagsLabel), nil, nil, nil, flagTabs)
+
+		split := container.NewHSplit(listPane, editPane)
+		split.SetOffset(0.2)
+
+		list.Select(0)
+
+		composeFileEntry := widget.NewEntry()
+		composeFileEntry.SetText(values[fs.ComposeFile])
+		composeFileEntry.SetPlaceHolder("Select docker-compose.yml")
+
+		composeEnvEntry := widget.NewEntry()
+		composeEnvEntry.SetText(values[fs.ComposeEnvFile])
+		composeEnvEntry.SetPlaceHolder("Optional .env file")
+
+		composeProjectEntry := widget.NewEntry()
+		composeProjectEntry.SetText(values[fs.ComposeProjectDir])
+		composeProjectEntry.SetPlaceHolder("Optional folder for build contexts and bind mounts")
+
+		composeProjectItem := widget.NewFormItem("Project folder", container.NewBorder(nil, nil, nil, newFolderBrowseButton(w, composeProjectEntry), composeProjectEntry))
+		composeProjectItem.HintText = "Synced next to the compose file so relative paths resolve; files on the device are kept"
+		composePane := widget.NewForm(
+			widget.NewFormItem("Compose file", container.NewBorder(nil, nil, nil, newFileBrowseButton(w, composeFileEntry, []string{".yml", ".yaml"}), composeFileEntry)),
+			widget.NewFormItem("Env file", container.NewBorder(nil, nil, nil, newFileBrowseButton(w, composeEnvEntry, nil), composeEnvEntry)),
+			composeProjectItem,
 		)
 
+		modeOptions := []string{containerModeCreateLabel, containerModeComposeLabel}
+		modeRadio := widget.NewRadioGroup(modeOptions, func(selectedMode string) {
+			if selectedMode == containerModeComposeLabel {
+				split.Hide()
+				composePane.Show()
+			} else {
+				composePane.Hide()
+				split.Show()
+			}
+		})
+		modeRadio.Horizontal = true
+		modeRadio.Required = true
+		if values[fs.ContainerMode] == install.ContainerModeCompose {
+			modeRadio.SetSelected(containerModeComposeLabel)
+		} else {
+			modeRadio.SetSelected(containerModeCreateLabel)
+		}
+
+		tarballEntry := widget.NewEntry()
+		tarballEntry.SetText(values[fs.ImageTarball])
+		tarballEntry.SetPlaceHolder("Select docker save tarball (.tar)")
+		tarballRow := container.NewBorder(nil, nil, nil, newFileBrowseButton(w, tarballEntry, []string{".tar", ".gz", ".tgz"}), tarballEntry)
+
+		sourceSelect := widget.NewSelect(imageSourceLabels(), func(label string) {
+			if imageSourceFromLabel(label) == install.ImageSourceTarball {
+				tarballRow.Show()
+			} else {
+				tarballRow.Hide()
+			}
+		})
+		sourceSelect.SetSelected(imageSourceLabel(values[fs.ImageSource]))
+
+		cleanupDescription := widget.NewLabel("")
+		cleanupDescription.Wrapping = fyne.TextWrapWord
+		cleanupSelect := widget.NewSelect(dockerCleanupLabels(), func(label string) {
+			cleanupDescription.SetText(dockerCleanupDescription(dockerCleanupFromLabel(label)))
+		})
+		cleanupSelect.SetSelected(dockerCleanupLabel(values[fs.DockerCleanup]))
+
+		top := container.NewVBox(
+			modeRadio,
+			widget.NewForm(
+				widget.NewFormItem("Image source", sourceSelect),
+				widget.NewFormItem("Cleanup before deploy", cleanupSelect),
+			),
+			cleanupDescription,
+			tarballRow,
+		)
+		content := container.NewBorder(top, nil, nil, nil, container.NewStack(split, container.NewVBox(composePane)))
+
 		dialogWindow := dialog.NewCustomConfirm(
 			"Container Settings",
 			"Save",
@@ -44,13 +321,55 @@ func BuildContainerPrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.But
 					return
 				}
 
-				updated := make(fs.EnvConfig, len(values)+2)
+				storeSelected()
+				if modeRadio.Selected != containerModeComposeLabel {
+					if err := validateContainerConfigs(containers); err != nil {
+						dialog.ShowError(err, w)
+						return
+					}
+					if err := validateContainerFlags(containers); err != nil {
+						dialog.ShowError(err, w)
+						return
+					}
+				}
+
+				updated := make(fs.EnvConfig, len(values)+4*len(containers))
 				for key, value := range values {
 					updated[key] = value
 				}
 
-				updated[fs.ContainerImage] = strings.TrimSpace(imageEntry.Text)
-				updated[fs.ContainerCommand] = fs.EncodeMultilineValue(strings.TrimSpace(commandEntry.Text))
+				composeMode := modeRadio.Selected == containerModeComposeLabel
+				if composeMode && strings.TrimSpace(composeFileEntry.Text) == "" {
+					dialog.ShowError(fmt.Errorf("please select a compose file"), w)
+					return
+				}
+
+				if composeMode {
+					updated[fs.ContainerMode] = install.ContainerModeCompose
+				} else {
+					updated[fs.ContainerMode] = install.ContainerModeCreate
+				}
+				imageSource := imageSourceFromLabel(sourceSelect.Selected)
+				if imageSource == install.ImageSourceTarball && strings.TrimSpace(tarballEntry.Text) == "" {
+					dialog.ShowError(fmt.Errorf("please select an image tarball"), w)
+					return
+				}
+				if imageSource == install.ImageSourceStation && composeMode {
+					dialog.ShowError(fmt.Errorf("station pull needs container definitions; use an image tarball with compose"), w)
+					return
+				}
+				updated[fs.ImageSource] = imageSource
+				updated[fs.DockerCleanup] = dockerCleanupFromLabel(cleanupSelect.Selected)
+				updated[fs.ImageTarball] = strings.TrimSpace(tarballEntry.Text)
+				updated[fs.ComposeFile] = strings.TrimSpace(composeFileEntry.Text)
+				updated[fs.ComposeEnvFile] = strings.TrimSpace(composeEnvEntry.Text)
+				updated[fs.ComposeProjectDir] = strings.TrimSpace(composeProjectEntry.Text)
+
+				if len(containers) == 1 && containers[0].Image == "" && containers[0].Command == "" {
+					fs.StoreContainers(updated, nil)
+				} else {
+					fs.StoreContainers(updated, containers)
+				}
 
 				if err := fs.SaveConfig(updated); err != nil {
 					dialog.ShowError(err, w)
@@ -69,3 +388,157 @@ func BuildContainerPrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.But
 
 	return containerBtn
 }
+
+var imageSourceOptions = []struct {
+	value string
+	label string
+}{
+	{install.ImageSourceRegistry, "Registry (device pulls)"},
+	{install.ImageSourceTarball, "Local docker save tarball"},
+	{install.ImageSourceStation, "Pull and save on station"},
+}
+
+func imageSourceLabels() []string {
+	labels := make([]string, 0, len(imageSourceOptions))
+	for _, option := range imageSourceOptions {
+		labels = append(labels, option.label)
+	}
+	return labels
+}
+
+func imageSourceLabel(value string) string {
+	for _, option := range imageSourceOptions {
+		if option.value == value {
+			return option.label
+		}
+	}
+	return imageSourceOptions[0].label
+}
+
+func imageSourceFromLabel(label string) string {
+	for _, option := range imageSourceOptions {
+		if option.label == label {
+			return option.value
+		}
+	}
+	return install.ImageSourceRegistry
+}
+
+var dockerCleanupOptions = []struct {
+	value       string
+	label       string
+	description string
+}{
+	{install.DockerCleanupWipeAll, "Remove everything", "Removes all containers with their anonymous volumes and all images. Named volumes are kept."},
+	{install.DockerCleanupManaged, "Only wago-init containers", "Removes only containers created by wago-init (label " + install.ManagedContainerLabel + ") or by its compose deployment. Images and volumes are kept."},
+	{install.DockerCleanupKeepVolumes, "Containers and images, keep volumes", "Removes all containers and images but keeps every volume, including anonymous ones."},
+	{install.DockerCleanupNone, "No cleanup", "Leaves existing containers, images and volumes untouched."},
+}
+
+func dockerCleanupLabels() []string {
+	labels := make([]string, 0, len(dockerCleanupOptions))
+	for _, option := range dockerCleanupOptions {
+		labels = append(labels, option.label)
+	}
+	return labels
+}
+
+func dockerCleanupLabel(value string) string {
+	for _, option := range dockerCleanupOptions {
+		if option.value == value {
+			return option.label
+		}
+	}
+	return dockerCleanupOptions[0].label
+}
+
+func dockerCleanupFromLabel(label string) string {
+	for _, option := range dockerCleanupOptions {
+		if option.label == label {
+			return option.value
+		}
+	}
+	return install.DockerCleanupWipeAll
+}
+
+func dockerCleanupDescription(value string) string {
+	for _, option := range dockerCleanupOptions {
+		if option.value == value {
+			return option.description + " The session log lists everything before it is removed."
+		}
+	}
+	return ""
+}
+
+func containerListLabel(cfg fs.ContainerConfig, index int) string {
+	name := cfg.Name
+	if name == "" {
+		name = fmt.Sprintf("Container %d", index+1)
+	}
+	return fmt.Sprintf("%d. %s", cfg.StartOrder, name)
+}
+
+func nextStartOrder(containers []fs.ContainerConfig) int {
+	highest := 0
+	for _, cfg := range containers {
+		if cfg.StartOrder > highest {
+			highest = cfg.StartOrder
+		}
+	}
+	return highest + 1
+}
+
+func validateContainerConfigs(containers []fs.ContainerConfig) error {
+	seen := make(map[string]struct{}, len(containers))
+	for i, cfg := range containers {
+		if cfg.Image == "" && (len(containers) > 1 || cfg.Command != "") {
+			return fmt.Errorf("container %d has no image", i+1)
+		}
+		if cfg.Digest != "" && !digestPattern.MatchString(cfg.Digest) {
+			return fmt.Errorf("container %d has an invalid digest; expected sha256:<64 hex characters>", i+1)
+		}
+		if len(containers) > 1 && cfg.Name == "" {
+			return fmt.Errorf("container %d needs a name when several containers are configured", i+1)
+		}
+		if cfg.Name == "" {
+			continue
+		}
+		if _, exists := seen[cfg.Name]; exists {
+			return fmt.Errorf("container name '%s' is used more than once", cfg.Name)
+		}
+		seen[cfg.Name] = struct{}{}
+	}
+	return nil
+}
+
+// validateContainerFlags parses and checks the stored flags of every container so that typos are reported
+// before a session starts instead of when docker create fails on the device.
+func validateContainerFlags(containers []fs.ContainerConfig) error {
+	for i, cfg := range containers {
+		if err := install.ValidateContainerCommand(cfg.Command); err != nil {
+			name := cfg.Name
+			if name == "" {
+				name = fmt.Sprintf("%d", i+1)
+			}
+			return fmt.Errorf("container %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func newFlagListEntry(placeholder string) *widget.Entry {
+	entry := widget.NewMultiLineEntry()
+	entry.SetPlaceHolder(placeholder)
+	entry.SetMinRowsVisible(3)
+	return entry
+}
+
+func nonEmptyLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			lines = append(lines, trimmed)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,268 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"wago-init/internal/fs"
+	"wago-init/internal/install"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+func BuildCopyPrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.Button {
+	copyBtn := widget.NewButton("Copy settings", func() {
+		values := fs.EnvConfig{}
+		if configValues != nil && *configValues != nil {
+			values = *configValues
+		}
+
+		mappings := fs.LoadCopyMappings(values)
+		if len(mappings) == 0 {
+			mappings = []fs.CopyMapping{{Remote: fs.DefaultCopyRemotePath}}
+		}
+		selected := -1
+
+		localEntry := widget.NewEntry()
+		localEntry.SetPlaceHolder("Local file, directory, .zip/.tar.gz archive or git repository")
+		localRow := container.NewBorder(nil, nil, nil,
+			container.NewHBox(newFolderBrowseButton(w, localEntry), newFileBrowseButton(w, localEntry, nil)), localEntry)
+
+		gitRefEntry := widget.NewEntry()
+		gitRefEntry.SetPlaceHolder("Optional tag, branch or commit when the local path is a git repository")
+
+		remoteEntry := widget.NewEntry()
+		remoteEntry.SetPlaceHolder(fs.DefaultCopyRemotePath)
+
+		ownerEntry := widget.NewEntry()
+		ownerEntry.SetPlaceHolder("Keep")
+		groupEntry := widget.NewEntry()
+		groupEntry.SetPlaceHolder("Keep")
+
+		fileModeEntry := widget.NewEntry()
+		fileModeEntry.SetPlaceHolder("e.g., 644")
+		dirModeEntry := widget.NewEntry()
+		dirModeEntry.SetPlaceHolder("e.g., 755")
+
+		postCommandEntry := widget.NewMultiLineEntry()
+		postCommandEntry.SetPlaceHolder("Optional, e.g., /etc/init.d/lighttpd restart")
+		postCommandEntry.SetMinRowsVisible(3)
+
+		var list *widget.List
+
+		storeSelected := func() {
+			if selected < 0 || selected >= len(mappings) {
+				return
+			}
+			mappings[selected] = fs.CopyMapping{
+				Local:       strings.TrimSpace(localEntry.Text),
+				GitRef:      strings.TrimSpace(gitRefEntry.Text),
+				Remote:      strings.TrimSpace(remoteEntry.Text),
+				Owner:       strings.TrimSpace(ownerEntry.Text),
+				Group:       strings.TrimSpace(groupEntry.Text),
+				FileMode:    strings.TrimSpace(fileModeEntry.Text),
+				DirMode:     strings.TrimSpace(dirModeEntry.Text),
+				PostCommand: fs.EncodeMultilineValue(strings.TrimSpace(postCommandEntry.Text)),
+			}
+		}
+
+		showSelected := func() {
+			current := mappings[selected]
+			localEntry.SetText(current.Local)
+			gitRefEntry.SetText(current.GitRef)
+			remoteEntry.SetText(current.Remote)
+			ownerEntry.SetText(current.Owner)
+			groupEntry.SetText(current.Group)
+			fileModeEntry.SetText(current.FileMode)
+			dirModeEntry.SetText(current.DirMode)
+			postCommandEntry.SetText(fs.DecodeMultilineValue(current.PostCommand))
+		}
+
+		list = widget.NewList(
+			func() int {
+				return len(mappings)
+			},
+			func() fyne.CanvasObject {
+				return widget.NewLabel("")
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				obj.(*widget.Label).SetText(copyMappingListLabel(mappings[id], id))
+			},
+		)
+		list.OnSelected = func(id widget.ListItemID) {
+			if id == selected {
+				return
+			}
+			storeSelected()
+			selected = id
+			showSelected()
+			list.Refresh()
+		}
+
+		addBtn := widget.NewButton("Add", func() {
+			storeSelected()
+			mappings = append(mappings, fs.CopyMapping{Remote: fs.DefaultCopyRemotePath})
+			list.Refresh()
+			list.Select(len(mappings) - 1)
+		})
+
+		removeBtn := widget.NewButton("Remove", func() {
+			if selected < 0 || len(mappings) <= 1 {
+				return
+			}
+			mappings = append(mappings[:selected], mappings[selected+1:]...)
+			selected = -1
+			list.UnselectAll()
+			list.Refresh()
+			list.Select(0)
+		})
+
+		listPane := container.NewBorder(nil, container.NewGridWithColumns(2, addBtn, removeBtn), nil, nil, list)
+
+		form := widget.NewForm(
+			widget.NewFormItem("Local path", localRow),
+			widget.NewFormItem("Git ref", gitRefEntry),
+			widget.NewFormItem("Remote path", remoteEntry),
+			widget.NewFormItem("Owner", ownerEntry),
+			widget.NewFormItem("Group", groupEntry),
+			widget.NewFormItem("File mode", fileModeEntry),
+			widget.NewFormItem("Directory mode", dirModeEntry),
+			widget.NewFormItem("Post-copy command", postCommandEntry),
+		)
+
+		split := container.NewHSplit(listPane, container.NewVScroll(form))
+		split.SetOffset(0.3)
+
+		list.Select(0)
+
+		templatesCheck := widget.NewCheck("Render *.tmpl files with device data", nil)
+		templatesCheck.SetChecked(values[fs.ConfigTemplates] == "true")
+
+		deleteCheck := widget.NewCheck("Delete files on the device that are missing locally", nil)
+		deleteCheck.SetChecked(values[fs.CopySyncDelete] == "true")
+
+		dryRunCheck := widget.NewCheck("Dry run: only log the changes", nil)
+		dryRunCheck.SetChecked(values[fs.CopyDryRun] == "true")
+
+		syncCheck := widget.NewCheck("Sync: transfer only new and changed files", func(checked bool) {
+			if checked {
+				deleteCheck.Enable()
+				dryRunCheck.Enable()
+			} else {
+				deleteCheck.Disable()
+				dryRunCheck.Disable()
+			}
+		})
+		syncCheck.SetChecked(values[fs.CopySync] == "true")
+		syncCheck.OnChanged(syncCheck.Checked)
+
+		levelSelect := widget.NewSelect(compressionLevelOptions(), nil)
+		levelSelect.SetSelected(values[fs.CopyCompressLevel])
+		if levelSelect.Selected == "" {
+			levelSelect.SetSelected(defaultCompressionLevel)
+		}
+
+		compressCheck := widget.NewCheck("Compress transfers with gzip when the device supports it", func(checked bool) {
+			if checked {
+				levelSelect.Enable()
+			} else {
+				levelSelect.Disable()
+			}
+		})
+		compressCheck.SetChecked(values[fs.CopyCompress] != "false")
+		compressCheck.OnChanged(compressCheck.Checked)
+
+		backupCheck := widget.NewCheck("Back up replaced files on the device before copying", nil)
+		backupCheck.SetChecked(values[fs.CopyBackup] != "false")
+
+		excludeEntry := widget.NewMultiLineEntry()
+		excludeEntry.SetText(fs.DecodeMultilineValue(values[fs.CopyExclude]))
+		excludeEntry.SetPlaceHolder(".git/\n*.swp\nsecrets/")
+		excludeEntry.SetMinRowsVisible(3)
+
+		options := container.NewVBox(
+			templatesCheck,
+			syncCheck,
+			container.NewPadded(container.NewVBox(deleteCheck, dryRunCheck)),
+			container.NewHBox(compressCheck, widget.NewLabel("Level"), levelSelect),
+			backupCheck,
+			widget.NewForm(widget.NewFormItem("Exclude patterns", excludeEntry)),
+			widget.NewLabel("Patterns use .gitignore syntax and apply to every mapping, in addition to .wagoignore files."),
+			widget.NewSeparator(),
+		)
+		content := container.NewBorder(options, nil, nil, nil, split)
+
+		dialogWindow := dialog.NewCustomConfirm(
+			"Copy Settings",
+			"Save",
+			"Cancel",
+			content,
+			func(ok bool) {
+				if !ok {
+					return
+				}
+
+				storeSelected()
+				if len(mappings) == 1 && mappings[0].Local == "" {
+					mappings = nil
+				}
+				if _, err := install.CopyMappingsFromConfig(mappings); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+
+				updated := make(fs.EnvConfig, len(values)+4+8*len(mappings))
+				for key, value := range values {
+					updated[key] = value
+				}
+				fs.StoreCopyMappings(updated, mappings)
+				updated[fs.ConfigTemplates] = strconv.FormatBool(templatesCheck.Checked)
+				updated[fs.CopySync] = strconv.FormatBool(syncCheck.Checked)
+				updated[fs.CopySyncDelete] = strconv.FormatBool(syncCheck.Checked && deleteCheck.Checked)
+				updated[fs.CopyDryRun] = strconv.FormatBool(syncCheck.Checked && dryRunCheck.Checked)
+				updated[fs.CopyCompress] = strconv.FormatBool(compressCheck.Checked)
+				updated[fs.CopyCompressLevel] = levelSelect.Selected
+				updated[fs.CopyBackup] = strconv.FormatBool(backupCheck.Checked)
+				updated[fs.CopyExclude] = fs.EncodeMultilineValue(strings.TrimSpace(excludeEntry.Text))
+
+				if err := fs.SaveConfig(updated); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+
+				if configValues != nil {
+					*configValues = updated
+				}
+			},
+			w,
+		)
+		dialogWindow.Resize(fyne.NewSize(1200, 700))
+		dialogWindow.Show()
+	})
+
+	return copyBtn
+}
+
+// defaultCompressionLevel matches the gzip default and balances station CPU against transfer size.
+const defaultCompressionLevel = "6"
+
+func compressionLevelOptions() []string {
+	options := make([]string, 0, 9)
+	for level := 1; level <= 9; level++ {
+		options = append(options, strconv.Itoa(level))
+	}
+	return options
+}
+
+func copyMappingListLabel(mapping fs.CopyMapping, index int) string {
+	if mapping.Local == "" {
+		return fmt.Sprintf("Mapping %d", index+1)
+	}
+	if mapping.GitRef != "" {
+		return fmt.Sprintf("%s@%s → %s", mapping.Local, mapping.GitRef, mapping.Remote)
+	}
+	return fmt.Sprintf("%s → %s", mapping.Local, mapping.Remote)
+}
@@ -0,0 +1,145 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wago-init/internal/fs"
+	"wago-init/internal/install"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// toggleDiagnostics cancels the running diagnostics collection or, when none runs, starts a new one.
+func (s *installSession) toggleDiagnostics() {
+	s.mu.Lock()
+	cancel := s.diagnosticsCancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		return
+	}
+	s.promptDiagnostics()
+}
+
+// promptDiagnostics asks where to store the diagnostics bundle of the session device and which remote
+// paths to include, then collects it in the background. Its output goes to the session log.
+func (s *installSession) promptDiagnostics() {
+	values := s.mv.configValues
+
+	targetEntry := widget.NewEntry()
+	targetEntry.SetText(defaultDiagnosticsPath(s.ip))
+	targetRow := container.NewBorder(nil, nil, nil, newFileSaveButton(s.mv.window, targetEntry), targetEntry)
+
+	pathsEntry := widget.NewMultiLineEntry()
+	pathsText := fs.DecodeMultilineValue(values[fs.DiagnosticsPaths])
+	if strings.TrimSpace(pathsText) == "" {
+		pathsText = strings.Join(install.DefaultDiagnosticsPaths, "\n")
+	}
+	pathsEntry.SetText(pathsText)
+	pathsEntry.SetPlaceHolder("/var/log\n/etc\n/root")
+	pathsEntry.SetMinRowsVisible(4)
+
+	form := widget.NewForm(
+		widget.NewFormItem("Save to", targetRow),
+		widget.NewFormItem("Remote paths", pathsEntry),
+	)
+	content := container.NewVBox(
+		form,
+		widget.NewLabel("A path ending in .tar.gz is written as archive, any other path is used as folder.\nfwupdate status, docker ps -a, container logs, dmesg and df are always included."),
+	)
+
+	dlg := dialog.NewCustomConfirm("Collect diagnostics for "+s.ip, "Collect", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		target := strings.TrimSpace(targetEntry.Text)
+		if target == "" {
+			dialog.ShowError(fmt.Errorf("choose where to save the diagnostics"), s.mv.window)
+			return
+		}
+		paths := nonEmptyLines(pathsEntry.Text)
+		for _, remotePath := range paths {
+			if !strings.HasPrefix(remotePath, "/") {
+				dialog.ShowError(fmt.Errorf("remote path '%s' must be absolute", remotePath), s.mv.window)
+				return
+			}
+		}
+
+		updated := cloneEnvConfig(s.mv.configValues)
+		updated[fs.DiagnosticsPaths] = fs.EncodeMultilineValue(strings.Join(paths, "\n"))
+		if err := fs.SaveConfig(updated); err != nil {
+			dialog.ShowError(err, s.mv.window)
+			return
+		}
+		s.mv.configValues = updated
+
+		opts := install.CopyOptions{Compress: updated[fs.CopyCompress] != "false"}
+		// The diagnostics outlive a finished session, so they get a context of their own that the
+		// button cancels while they run.
+		ctx, cancel := context.WithCancel(context.Background())
+		s.mu.Lock()
+		s.diagnosticsCancel = cancel
+		s.mu.Unlock()
+		s.diagnosticsBtn.SetText("Cancel diagnostics")
+		go s.collectDiagnostics(ctx, target, paths, opts)
+	}, s.mv.window)
+	dlg.Resize(fyne.NewSize(700, 360))
+	dlg.Show()
+}
+
+func (s *installSession) collectDiagnostics(ctx context.Context, target string, paths []string, opts install.CopyOptions) {
+	defer func() {
+		s.mu.Lock()
+		cancel := s.diagnosticsCancel
+		s.diagnosticsCancel = nil
+		s.mu.Unlock()
+		cancel()
+		s.mv.runOnUI(func() {
+			s.diagnosticsBtn.SetText("Collect diagnostics")
+		})
+	}()
+
+	client, _, err := install.InitSshClient(ctx, s.ip, s.mv.passwordPrompt)
+	if err != nil {
+		s.appendLog("Diagnostics failed: "+err.Error(), "")
+		s.mv.runOnUI(func() {
+			dialog.ShowError(err, s.mv.window)
+		})
+		return
+	}
+	defer client.Close()
+
+	err = install.CollectDiagnostics(client, ctx, target, paths, opts, s.appendLog)
+	if errors.Is(err, context.Canceled) {
+		s.appendLog("Diagnostics cancelled; "+target+" is incomplete.", "")
+		return
+	}
+	if err != nil {
+		s.appendLog("Diagnostics failed: "+err.Error(), "")
+		s.mv.runOnUI(func() {
+			dialog.ShowError(err, s.mv.window)
+		})
+		return
+	}
+	s.mv.runOnUI(func() {
+		dialog.ShowInformation("Diagnostics collected", "Saved to "+target, s.mv.window)
+	})
+}
+
+func defaultDiagnosticsPath(ip string) string {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		dir = "."
+	}
+	name := fmt.Sprintf("diagnostics-%s-%s.tar.gz", strings.ReplaceAll(ip, ".", "-"), time.Now().Format("20060102-150405"))
+	return filepath.Join(dir, name)
+}
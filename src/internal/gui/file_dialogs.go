@@ -0,0 +1,105 @@
+package gui
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newFileBrowseButton returns a button that opens a file dialog and writes the chosen path into entry.
+// An empty extensions list shows all files.
+func newFileBrowseButton(w fyne.Window, entry *widget.Entry, extensions []string) *widget.Button {
+	return widget.NewButton("Browse", func() {
+		fileDialog := dialog.NewFileOpen(func(read fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if read == nil {
+				return
+			}
+			defer read.Close()
+
+			entry.SetText(localPathFromURI(read.URI()))
+		}, w)
+		if len(extensions) > 0 {
+			fileDialog.SetFilter(storage.NewExtensionFileFilter(extensions))
+		}
+
+		currentPath := strings.TrimSpace(entry.Text)
+		if currentPath != "" {
+			uri := storage.NewFileURI(filepath.Dir(currentPath))
+			if listURI, err := storage.ListerForURI(uri); err == nil {
+				fileDialog.SetLocation(listURI)
+			}
+		}
+
+		fileDialog.Show()
+	})
+}
+
+// newFolderBrowseButton returns a button that opens a folder dialog and writes the chosen path into entry.
+func newFolderBrowseButton(w fyne.Window, entry *widget.Entry) *widget.Button {
+	return widget.NewButton("Folder", func() {
+		folderDialog := dialog.NewFolderOpen(func(list fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if list == nil {
+				return
+			}
+			entry.SetText(localPathFromURI(list))
+		}, w)
+
+		currentPath := strings.TrimSpace(entry.Text)
+		if currentPath != "" {
+			if listURI, err := storage.ListerForURI(storage.NewFileURI(currentPath)); err == nil {
+				folderDialog.SetLocation(listURI)
+			}
+		}
+
+		folderDialog.Show()
+	})
+}
+
+// newFileSaveButton returns a button that opens a save dialog and writes the chosen path into entry.
+func newFileSaveButton(w fyne.Window, entry *widget.Entry) *widget.Button {
+	return widget.NewButton("Browse", func() {
+		saveDialog := dialog.NewFileSave(func(write fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if write == nil {
+				return
+			}
+			defer write.Close()
+
+			entry.SetText(localPathFromURI(write.URI()))
+		}, w)
+
+		currentPath := strings.TrimSpace(entry.Text)
+		if currentPath != "" {
+			saveDialog.SetFileName(filepath.Base(currentPath))
+			if listURI, err := storage.ListerForURI(storage.NewFileURI(filepath.Dir(currentPath))); err == nil {
+				saveDialog.SetLocation(listURI)
+			}
+		}
+
+		saveDialog.Show()
+	})
+}
+
+func localPathFromURI(uri fyne.URI) string {
+	path := uri.Path()
+	if runtime.GOOS == "windows" && strings.HasPrefix(path, "/") && len(path) > 2 && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.Clean(filepath.FromSlash(path))
+}
@@ -5,7 +5,9 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"wago-init/internal/fileserver"
 	"wago-init/internal/fs"
+	"wago-init/internal/install"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -72,11 +74,43 @@ func BuildFirmwarePrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.Butt
 			fileDialog.Show()
 		}
 
+		healthCheckEntry := widget.NewMultiLineEntry()
+		healthCheckEntry.SetText(fs.DecodeMultilineValue(values[fs.FirmwareHealthCheck]))
+		healthCheckEntry.SetPlaceHolder(strings.Join(install.DefaultFirmwareHealthChecks, "\n"))
+		healthCheckEntry.SetMinRowsVisible(4)
+
+		httpServerCheck := widget.NewCheck("Serve firmware over station HTTP server", nil)
+		httpServerCheck.SetChecked(values[fs.HTTPServerEnabled] == "true")
+
+		interfaceOptions := []string{allInterfacesOption}
+		for _, address := range fileserver.ListInterfaceAddresses() {
+			interfaceOptions = append(interfaceOptions, address.String())
+		}
+		interfaceSelect := widget.NewSelect(interfaceOptions, nil)
+		interfaceSelect.SetSelected(allInterfacesOption)
+		for _, option := range interfaceOptions {
+			if ip := strings.TrimSpace(values[fs.HTTPServerInterface]); ip != "" && strings.HasPrefix(option, ip+" ") {
+				interfaceSelect.SetSelected(option)
+			}
+		}
+
+		portEntry := widget.NewEntry()
+		portEntry.SetText(values[fs.HTTPServerPort])
+		portEntry.SetPlaceHolder("random")
+		portEntryContainer := container.NewGridWrap(fyne.NewSize(90, portEntry.MinSize().Height), portEntry)
+
 		content := container.NewVBox(
 			container.NewHBox(widget.NewForm(widget.NewFormItem("Firmware Revision", revisionEntryContainer)), forceFirmwareCheck),
 			widget.NewLabel("Firmware Update File"),
 			fileEntry,
 			container.NewBorder(browseBtn, nil, nil, nil, nil),
+			widget.NewLabel("Health check before confirming new firmware (one command per line)"),
+			healthCheckEntry,
+			httpServerCheck,
+			widget.NewForm(
+				widget.NewFormItem("Interface", interfaceSelect),
+				widget.NewFormItem("Port", portEntryContainer),
+			),
 		)
 
 		revisionEntry.Resize(fyne.NewSize(320, revisionEntry.MinSize().Height))
@@ -99,6 +133,10 @@ func BuildFirmwarePrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.Butt
 				updated[fs.FirmwareRevision] = strings.TrimSpace(revisionEntry.Text)
 				updated[fs.FirmwarePath] = strings.TrimSpace(fileEntry.Text)
 				updated[fs.ForceFirmwareUpdate] = strings.TrimSpace(strconv.FormatBool(forceFirmwareCheck.Checked))
+				updated[fs.FirmwareHealthCheck] = fs.EncodeMultilineValue(strings.TrimSpace(healthCheckEntry.Text))
+				updated[fs.HTTPServerEnabled] = strconv.FormatBool(httpServerCheck.Checked)
+				updated[fs.HTTPServerInterface] = interfaceIP(interfaceSelect.Selected)
+				updated[fs.HTTPServerPort] = strings.TrimSpace(portEntry.Text)
 
 				if err := fs.SaveConfig(updated); err != nil {
 					dialog.ShowError(err, w)
@@ -112,7 +150,7 @@ func BuildFirmwarePrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.Butt
 			w,
 		)
 
-		dialogWindow.Resize(fyne.NewSize(800, 260))
+		dialogWindow.Resize(fyne.NewSize(800, 560))
 		dialogWindow.Show()
 	})
 
@@ -17,9 +17,15 @@ import (
 
 const progressDelayInterval = 12090 * time.Millisecond
 
+// installAction is the action of the sessions started with the Start button.
+const installAction = "Installation"
+
+const transferWaitingStatus = "Waiting for transfer slot"
+
 type installSession struct {
-	mv *mainView
-	ip string
+	mv     *mainView
+	ip     string
+	action string
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -31,19 +37,23 @@ type installSession struct {
 	userCancelled   bool
 	unlockStartOnce sync.Once
 	unlockStartFn   func()
-
-	ipLabel     *widget.Label
-	macLabel    *widget.Label
-	serialLabel *widget.Label
-	progress    *widget.ProgressBar
-	logBtn      *widget.Button
-	actionBtn   *widget.Button
-	statusLabel *widget.Label
-	statusBadge *canvas.Text
-	lastLog     *widget.Label
-	row         *fyne.Container
-	logEntry    *widget.Entry
-	logDialog   dialog.Dialog
+	// diagnosticsCancel stops the running diagnostics collection; nil while none runs.
+	diagnosticsCancel context.CancelFunc
+
+	ipLabel        *widget.Label
+	macLabel       *widget.Label
+	serialLabel    *widget.Label
+	progress       *widget.ProgressBar
+	logBtn         *widget.Button
+	diagnosticsBtn *widget.Button
+	restoreBtn     *widget.Button
+	actionBtn      *widget.Button
+	statusLabel    *widget.Label
+	statusBadge    *canvas.Text
+	lastLog        *widget.Label
+	row            *fyne.Container
+	logEntry       *widget.Entry
+	logDialog      dialog.Dialog
 }
 
 func (s *installSession) macValue() string {
@@ -71,6 +81,7 @@ func (mv *mainView) newInstallSession(ip string) *installSession {
 	session := &installSession{
 		mv:     mv,
 		ip:     ip,
+		action: installAction,
 		ctx:    ctx,
 		cancel: cancel,
 		status: "Running",
@@ -89,6 +100,15 @@ func (mv *mainView) newInstallSession(ip string) *installSession {
 		session.showLogs()
 	})
 
+	session.diagnosticsBtn = widget.NewButton("Collect diagnostics", func() {
+		session.toggleDiagnostics()
+	})
+
+	session.restoreBtn = widget.NewButton("Restore previous config", func() {
+		session.startRestore()
+	})
+	session.restoreBtn.Disable()
+
 	session.actionBtn = widget.NewButton("Cancel", func() {
 		session.confirmCancel()
 	})
@@ -102,7 +122,7 @@ func (mv *mainView) newInstallSession(ip string) *installSession {
 	statusLeft := container.NewHBox(session.statusBadge, session.statusLabel)
 	statusRow := container.NewBorder(nil, nil, statusLeft, session.lastLog)
 
-	top := container.NewBorder(nil, nil, container.NewHBox(session.ipLabel, session.macLabel, session.serialLabel), container.NewHBox(session.logBtn, session.actionBtn))
+	top := container.NewBorder(nil, nil, container.NewHBox(session.ipLabel, session.macLabel, session.serialLabel), container.NewHBox(session.logBtn, session.diagnosticsBtn, session.restoreBtn, session.actionBtn))
 	bottom := container.NewVBox(statusRow, widget.NewSeparator())
 	session.row = container.NewBorder(widget.NewSeparator(), bottom, nil, nil, container.NewVBox(top, session.progress))
 
@@ -192,6 +212,20 @@ func (s *installSession) appendLog(line, replaceIdentifier string) {
 	}
 }
 
+// setTransferWaiting shows in the status while the session waits for a transfer slot.
+func (s *installSession) setTransferWaiting(waiting bool) {
+	s.mu.Lock()
+	current := s.status
+	s.mu.Unlock()
+
+	switch {
+	case waiting && current == "Running":
+		s.setStatus(transferWaitingStatus)
+	case !waiting && current == transferWaitingStatus:
+		s.setStatus("Running")
+	}
+}
+
 func (s *installSession) setStatus(status string) {
 	s.mu.Lock()
 	s.status = status
@@ -312,7 +346,7 @@ func (s *installSession) updateProgress(value float64, targetValue float64) {
 
 func (s *installSession) reportSuccess() {
 	s.unlockStart()
-	s.appendLog("Installation completed successfully", "")
+	s.appendLog(s.action+" completed successfully", "")
 	s.mv.runOnUI(func() {
 		s.progress.SetValue(1)
 	})
@@ -326,6 +360,9 @@ func (s *installSession) reportFailure(err error) {
 	s.setStatus("Failed")
 	s.mv.runOnUI(func() {
 		s.statusBadge.Text = "  DEVICE SETUP FAILED!"
+		if s.action != installAction {
+			s.statusBadge.Text = "  " + strings.ToUpper(s.action) + " FAILED!"
+		}
 		s.statusBadge.Color = theme.Color(theme.ColorNameError)
 		s.statusBadge.Show()
 		s.statusBadge.Refresh()
@@ -336,10 +373,10 @@ func (s *installSession) reportFailure(err error) {
 func (s *installSession) reportCancellation() {
 	s.unlockStart()
 	if s.wasUserCancelled() {
-		s.appendLog("Installation cancelled by user", "")
+		s.appendLog(s.action+" cancelled by user", "")
 		s.setStatus("Cancelled by user")
 	} else {
-		s.appendLog("Installation cancelled", "")
+		s.appendLog(s.action+" cancelled", "")
 		s.setStatus("Cancelled")
 	}
 	s.finish()
@@ -352,8 +389,8 @@ func (s *installSession) confirmCancel() {
 	}
 
 	dialog.NewConfirm(
-		"Cancel installation?",
-		"Cancelling will stop the running installation for this device.",
+		"Cancel "+strings.ToLower(s.action)+"?",
+		"Cancelling will stop the running "+strings.ToLower(s.action)+" for this device.",
 		func(ok bool) {
 			if !ok {
 				return
@@ -385,6 +422,7 @@ func (s *installSession) finish() {
 	s.cancel()
 
 	s.mv.runOnUI(func() {
+		s.restoreBtn.Enable()
 		s.actionBtn.Enable()
 		s.actionBtn.SetText("Remove")
 		s.actionBtn.OnTapped = func() {
@@ -395,6 +433,33 @@ func (s *installSession) finish() {
 	})
 }
 
+// restart reuses the finished session row for another action on the same device. It reports false
+// while the session is still running.
+func (s *installSession) restart(action string) bool {
+	s.mu.Lock()
+	if !s.finished {
+		s.mu.Unlock()
+		return false
+	}
+	s.action = action
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.finished = false
+	s.userCancelled = false
+	s.mu.Unlock()
+
+	s.setStatus("Running")
+	s.mv.runOnUI(func() {
+		s.restoreBtn.Disable()
+		s.progress.SetValue(0)
+		s.statusBadge.Hide()
+		s.statusLabel.Show()
+		s.actionBtn.SetText("Cancel")
+		s.actionBtn.OnTapped = s.confirmCancel
+		s.actionBtn.Refresh()
+	})
+	return true
+}
+
 func (s *installSession) markUserCancelled() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -8,11 +8,14 @@ import (
 	"strings"
 	"sync"
 
-	"wago-init/internal/aws"
 	"wago-init/internal/fs"
 	"wago-init/internal/install"
+	"wago-init/internal/registry"
 
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
 )
 
 func (mv *mainView) handleStart() {
@@ -53,35 +56,80 @@ func (mv *mainView) handleStart() {
 		return
 	}
 
+	containers := fs.LoadContainers(mv.configValues)
+	if mv.configValues[fs.ContainerMode] != install.ContainerModeCompose {
+		if err := validateContainerFlags(containers); err != nil {
+			unlockStart()
+			dialog.ShowError(err, mv.window)
+			return
+		}
+	}
+
+	copyMappings, err := install.CopyMappingsFromConfig(fs.LoadCopyMappings(mv.configValues))
+	if err != nil {
+		unlockStart()
+		dialog.ShowError(err, mv.window)
+		return
+	}
+
+	variables, err := install.ParseVariableDefinitions(mv.configValues[fs.DeviceVariables])
+	if err != nil {
+		unlockStart()
+		dialog.ShowError(fmt.Errorf("device variables: %w", err), mv.window)
+		return
+	}
+
 	params := install.Parameters{
-		Ip:               ip,
-		FirmwareRevision: fwRevisionRaw,
-		PromptPassword:   mv.passwordPrompt,
-		ContainerImage:   mv.configValues[fs.ContainerImage],
-		ContainerFlags:   install.BuildContainerCommand(mv.configValues[fs.ContainerCommand]),
-		NewestFirmware:   fwTarget,
-		FirmwarePath:     strings.TrimSpace(mv.configValues[fs.FirmwarePath]),
-		ForceFirmware:    strings.TrimSpace(mv.configValues[fs.ForceFirmwareUpdate]) == "true",
+		Ip:                   ip,
+		FirmwareRevision:     fwRevisionRaw,
+		PromptPassword:       mv.passwordPrompt,
+		Containers:           install.ContainerDefinitionsFromConfig(containers),
+		ContainerMode:        mv.configValues[fs.ContainerMode],
+		ComposeFile:          strings.TrimSpace(mv.configValues[fs.ComposeFile]),
+		ComposeEnvFile:       strings.TrimSpace(mv.configValues[fs.ComposeEnvFile]),
+		ComposeProjectDir:    strings.TrimSpace(mv.configValues[fs.ComposeProjectDir]),
+		ImageSource:          mv.configValues[fs.ImageSource],
+		ImageTarball:         strings.TrimSpace(mv.configValues[fs.ImageTarball]),
+		DockerCleanup:        mv.configValues[fs.DockerCleanup],
+		Variables:            variables,
+		CopyMappings:         copyMappings,
+		NewestFirmware:       fwTarget,
+		FirmwarePath:         strings.TrimSpace(mv.configValues[fs.FirmwarePath]),
+		ForceFirmware:        strings.TrimSpace(mv.configValues[fs.ForceFirmwareUpdate]) == "true",
+		FirmwareHealthChecks: install.ParseHealthChecks(mv.configValues[fs.FirmwareHealthCheck]),
 	}
 
 	updated := cloneEnvConfig(mv.configValues)
-	updated[fs.ConfigPath] = strings.TrimSpace(mv.configPathEntry.Text)
 	updated[fs.IpAddress] = ip
 
-	awsRegion := strings.TrimSpace(updated[fs.AWSRegion])
-	awsAccountID := strings.TrimSpace(updated[fs.AWSAccountID])
-	awsAccessID := strings.TrimSpace(updated[fs.AWSAccessID])
-	awsAccessKey := strings.TrimSpace(updated[fs.AWSAccessKey])
+	// A local image tarball needs no registry access, neither on the station nor on the device.
+	var provider registry.Provider
+	if params.ImageSource != install.ImageSourceTarball {
+		var err error
+		provider, err = registry.FromConfig(updated)
+		if err != nil {
+			unlockStart()
+			dialog.ShowError(err, mv.window)
+			return
+		}
+	}
 
-	if awsRegion == "" || awsAccessID == "" || awsAccessKey == "" || awsAccountID == "" {
+	artifactServer, err := mv.ensureArtifactServer()
+	if err != nil {
 		unlockStart()
-		dialog.ShowError(fmt.Errorf("please provide AWS region, account id, access id, and access key before starting"), mv.window)
+		dialog.ShowError(fmt.Errorf("start station HTTP server: %w", err), mv.window)
 		return
 	}
+	params.ArtifactServer = artifactServer
+	params.TransferScheduler = mv.transferScheduler
 
 	session := mv.newInstallSession(ip)
 	session.setStartUnlocker(unlockStart)
 
+	params.ConfirmDockerCleanup = func(preview string) bool {
+		return mv.confirmDockerCleanup(session, preview)
+	}
+
 	params.PromptNewPassword = func() (string, bool) {
 		value, ok := mv.newPasswordPrompt(session)
 		if ok {
@@ -97,12 +145,21 @@ func (mv *mainView) handleStart() {
 	session.appendLog("Preparing installation...", "")
 
 	params.Context = session.ctx
-	params.ConfigPath = updated[fs.ConfigPath]
+	params.TransferWaiting = session.setTransferWaiting
+	params.ConfigTemplates = updated[fs.ConfigTemplates] == "true"
+	params.CopySync = updated[fs.CopySync] == "true"
+	params.CopySyncDelete = updated[fs.CopySyncDelete] == "true"
+	params.CopyDryRun = updated[fs.CopyDryRun] == "true"
+	params.CopyExcludes = install.ParseExcludePatterns(updated[fs.CopyExclude])
+	params.CopyCompress = updated[fs.CopyCompress] != "false"
+	params.CopyCompressionLevel, _ = strconv.Atoi(updated[fs.CopyCompressLevel])
+	params.CopyBackup = updated[fs.CopyBackup] != "false"
+	params.TemplateProfile = templateProfile(updated)
 
-	go mv.runInstallationSession(session, params, updated, awsRegion, awsAccountID, awsAccessID, awsAccessKey)
+	go mv.runInstallationSession(session, params, updated, provider)
 }
 
-func (mv *mainView) runInstallationSession(session *installSession, params install.Parameters, updated fs.EnvConfig, awsRegion, awsAccountID, awsAccessID, awsAccessKey string) {
+func (mv *mainView) runInstallationSession(session *installSession, params install.Parameters, updated fs.EnvConfig, provider registry.Provider) {
 	if err := fs.SaveConfig(updated); err != nil {
 		session.reportFailure(err)
 		return
@@ -110,32 +167,32 @@ func (mv *mainView) runInstallationSession(session *installSession, params insta
 
 	mv.runOnUI(func() {
 		mv.configValues = updated
-		mv.configPathEntry.SetText(updated[fs.ConfigPath])
 	})
 
 	session.appendLog("Configuration saved", "")
 
-	token, err := aws.FetchLoginPassword(session.ctx, awsRegion, awsAccessID, awsAccessKey)
-	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			session.reportCancellation()
+	if provider != nil {
+		creds, err := provider.Credentials(session.ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				session.reportCancellation()
+				return
+			}
+			session.reportFailure(err)
 			return
 		}
-		session.reportFailure(err)
-		return
-	}
 
-	params.AWSToken = token
-	params.AWSEcrUrl = aws.GetEcrUrl(awsAccountID, awsRegion)
+		params.Registry = creds
 
-	session.appendLog("Authorization with AWS successful", "")
+		session.appendLog("Authorization with "+provider.Name()+" successful", "")
+	}
 
 	if session.ctx.Err() != nil {
 		session.reportCancellation()
 		return
 	}
 
-	err = install.Install(
+	err := install.Install(
 		params,
 		session.appendLog,
 		session.updateProgress,
@@ -160,6 +217,54 @@ func (mv *mainView) hasActiveSessionForIP(ip string) bool {
 	return false
 }
 
+// confirmDockerCleanup shows the cleanup preview of session and blocks until the operator removes the
+// listed objects or aborts the installation. Cancelling the session counts as abort.
+func (mv *mainView) confirmDockerCleanup(session *installSession, preview string) bool {
+	resultCh := make(chan bool, 1)
+	answered := make(chan struct{})
+	defer close(answered)
+
+	mv.runOnUI(func() {
+		label := widget.NewLabel(preview)
+		label.Wrapping = fyne.TextWrapWord
+		scroll := container.NewVScroll(label)
+		scroll.SetMinSize(fyne.NewSize(560, 240))
+
+		dlg := dialog.NewCustomConfirm("Docker cleanup on "+session.ip, "Remove", "Abort installation", scroll, func(ok bool) {
+			select {
+			case resultCh <- ok:
+			default:
+			}
+		}, mv.window)
+		dlg.Show()
+		go func() {
+			select {
+			case <-session.ctx.Done():
+				mv.runOnUI(dlg.Hide)
+			case <-answered:
+			}
+		}()
+	})
+
+	select {
+	case ok := <-resultCh:
+		return ok
+	case <-session.ctx.Done():
+		return false
+	}
+}
+
+// templateProfile returns the station settings that config templates may use; credentials are left out.
+func templateProfile(values fs.EnvConfig) map[string]string {
+	profile := make(map[string]string, len(values))
+	for key, value := range values {
+		if !fs.IsSecretKey(key) {
+			profile[key] = value
+		}
+	}
+	return profile
+}
+
 func cloneEnvConfig(src fs.EnvConfig) fs.EnvConfig {
 	if src == nil {
 		return fs.EnvConfig{}
@@ -0,0 +1,144 @@
+package gui
+
+import (
+	"strings"
+	"wago-init/internal/fs"
+	"wago-init/internal/registry"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var registryTypeOptions = []struct {
+	value string
+	label string
+}{
+	{registry.TypeECR, "AWS ECR"},
+	{registry.TypeBasic, "Username / password (Docker Hub, Harbor, GitLab, GHCR)"},
+	{registry.TypeAnonymous, "Anonymous (public images)"},
+}
+
+func BuildRegistryPrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.Button {
+	registrySettingsBtn := widget.NewButton("Registry settings", func() {
+		values := fs.EnvConfig{}
+		if configValues != nil && *configValues != nil {
+			values = *configValues
+		}
+
+		awsRegionEntry := widget.NewEntry()
+		awsRegionEntry.SetText(values[fs.AWSRegion])
+
+		accountIDEntry := widget.NewEntry()
+		accountIDEntry.SetText(values[fs.AWSAccountID])
+
+		accessIDEntry := widget.NewEntry()
+		accessIDEntry.SetText(values[fs.AWSAccessID])
+
+		accessKeyEntry := widget.NewPasswordEntry()
+		accessKeyEntry.SetText(values[fs.AWSAccessKey])
+
+		ecrForm := widget.NewForm(
+			widget.NewFormItem("AWS Region", awsRegionEntry),
+			widget.NewFormItem("Account ID", accountIDEntry),
+			widget.NewFormItem("Access ID", accessIDEntry),
+			widget.NewFormItem("Access Key", accessKeyEntry),
+		)
+
+		serverEntry := widget.NewEntry()
+		serverEntry.SetText(values[fs.RegistryServer])
+		serverEntry.SetPlaceHolder("e.g., ghcr.io (empty for Docker Hub)")
+
+		usernameEntry := widget.NewEntry()
+		usernameEntry.SetText(values[fs.RegistryUsername])
+
+		passwordEntry := widget.NewPasswordEntry()
+		passwordEntry.SetText(values[fs.RegistryPassword])
+
+		basicForm := widget.NewForm(
+			widget.NewFormItem("Registry", serverEntry),
+			widget.NewFormItem("Username", usernameEntry),
+			widget.NewFormItem("Password / Token", passwordEntry),
+		)
+
+		labels := make([]string, 0, len(registryTypeOptions))
+		for _, option := range registryTypeOptions {
+			labels = append(labels, option.label)
+		}
+		typeSelect := widget.NewSelect(labels, func(label string) {
+			ecrForm.Hide()
+			basicForm.Hide()
+			switch registryTypeFromLabel(label) {
+			case registry.TypeECR:
+				ecrForm.Show()
+			case registry.TypeBasic:
+				basicForm.Show()
+			}
+		})
+		typeSelect.SetSelected(registryTypeLabel(values[fs.RegistryType]))
+
+		content := container.NewVBox(
+			widget.NewForm(widget.NewFormItem("Registry type", typeSelect)),
+			ecrForm,
+			basicForm,
+		)
+
+		d := dialog.NewCustomConfirm(
+			"Registry Settings",
+			"Save",
+			"Cancel",
+			content,
+			func(ok bool) {
+				if !ok {
+					return
+				}
+
+				updated := make(fs.EnvConfig, len(values)+8)
+				for key, value := range values {
+					updated[key] = value
+				}
+
+				updated[fs.RegistryType] = registryTypeFromLabel(typeSelect.Selected)
+				updated[fs.AWSRegion] = strings.TrimSpace(awsRegionEntry.Text)
+				updated[fs.AWSAccountID] = strings.TrimSpace(accountIDEntry.Text)
+				updated[fs.AWSAccessID] = strings.TrimSpace(accessIDEntry.Text)
+				updated[fs.AWSAccessKey] = strings.TrimSpace(accessKeyEntry.Text)
+				updated[fs.RegistryServer] = strings.TrimSpace(serverEntry.Text)
+				updated[fs.RegistryUsername] = strings.TrimSpace(usernameEntry.Text)
+				updated[fs.RegistryPassword] = strings.TrimSpace(passwordEntry.Text)
+
+				if err := fs.SaveConfig(updated); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+
+				if configValues != nil {
+					*configValues = updated
+				}
+			},
+			w,
+		)
+		d.Resize(fyne.NewSize(600, 300))
+		d.Show()
+	})
+	return registrySettingsBtn
+}
+
+func registryTypeLabel(value string) string {
+	for _, option := range registryTypeOptions {
+		if option.value == value {
+			return option.label
+		}
+	}
+	return registryTypeOptions[0].label
+}
+
+func registryTypeFromLabel(label string) string {
+	for _, option := range registryTypeOptions {
+		if option.label == label {
+			return option.value
+		}
+	}
+	return registry.TypeECR
+}
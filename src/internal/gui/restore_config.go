@@ -0,0 +1,110 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"wago-init/internal/install"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const restoreAction = "Config restore"
+
+type backupSelection struct {
+	backup install.ConfigBackup
+	ok     bool
+}
+
+// startRestore restores one of the config backups stored on the device of the finished session. The
+// restore runs in the same row, so its log follows the installation that wrote the backup.
+func (s *installSession) startRestore() {
+	if !s.restart(restoreAction) {
+		return
+	}
+	s.appendLog(fmt.Sprintf("Config restore started for %s", s.ip), "")
+	go s.mv.runRestoreSession(s)
+}
+
+func (mv *mainView) runRestoreSession(session *installSession) {
+	err := mv.restoreConfig(session)
+	switch {
+	case err == nil:
+		session.reportSuccess()
+	case errors.Is(err, context.Canceled):
+		session.reportCancellation()
+	default:
+		session.reportFailure(err)
+	}
+}
+
+func (mv *mainView) restoreConfig(session *installSession) error {
+	client, _, err := install.InitSshClient(session.ctx, session.ip, mv.passwordPrompt)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	session.appendLog("Connection to device established", "")
+	session.updateProgress(0.1, 0.1)
+
+	backups, err := install.ListConfigBackups(session.ctx, client)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no config backups found in %s on the device", install.ConfigBackupDir)
+	}
+	session.appendLog(fmt.Sprintf("Found %d config backup(s)", len(backups)), "")
+
+	backup, ok := mv.selectConfigBackup(backups)
+	if !ok {
+		session.markUserCancelled()
+		return context.Canceled
+	}
+	session.updateProgress(0.2, 0.9)
+
+	return install.RestoreConfigBackup(session.ctx, client, backup, session.appendLog)
+}
+
+// selectConfigBackup asks which backup to restore, the newest being preselected.
+func (mv *mainView) selectConfigBackup(backups []install.ConfigBackup) (install.ConfigBackup, bool) {
+	resultCh := make(chan backupSelection, 1)
+
+	fyne.Do(func() {
+		options := make([]string, len(backups))
+		for i, backup := range backups {
+			options[i] = fmt.Sprintf("%s (%d mapping(s))", backup.Time.Format("2006-01-02 15:04:05"), len(backup.Archives))
+		}
+		selectWidget := widget.NewSelect(options, nil)
+		selectWidget.SetSelectedIndex(0)
+
+		content := container.NewVBox(
+			widget.NewLabel("Installation to undo:"),
+			selectWidget,
+			widget.NewLabel("Files replaced by that installation are restored, files it created are removed."),
+		)
+
+		dlg := dialog.NewCustomConfirm("Restore previous config", "Restore", "Cancel", content, func(ok bool) {
+			index := selectWidget.SelectedIndex()
+			if !ok || index < 0 {
+				resultCh <- backupSelection{}
+				return
+			}
+			resultCh <- backupSelection{backup: backups[index], ok: true}
+		}, mv.window)
+		dlg.SetOnClosed(func() {
+			select {
+			case resultCh <- backupSelection{}:
+			default:
+			}
+		})
+		dlg.Show()
+	})
+
+	res := <-resultCh
+	return res.backup, res.ok
+}
@@ -0,0 +1,85 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"wago-init/internal/fs"
+	"wago-init/internal/install"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+func BuildStationPrompt(mv *mainView) *widget.Button {
+	stationBtn := widget.NewButton("Station settings", func() {
+		values := mv.configValues
+		if values == nil {
+			values = fs.EnvConfig{}
+		}
+
+		slotsEntry := widget.NewEntry()
+		slotsEntry.SetText(values[fs.TransferSlots])
+		slotsEntry.SetPlaceHolder(strconv.Itoa(install.DefaultTransferSlots))
+		slotsEntryContainer := container.NewGridWrap(fyne.NewSize(65, slotsEntry.MinSize().Height), slotsEntry)
+
+		content := container.NewVBox(
+			widget.NewForm(widget.NewFormItem("Concurrent transfers", slotsEntryContainer)),
+			widget.NewLabel("Limits firmware uploads, image loads and config copies running at the same time.\nUse 0 for no limit."),
+		)
+
+		dialogWindow := dialog.NewCustomConfirm(
+			"Station Settings",
+			"Save",
+			"Cancel",
+			content,
+			func(ok bool) {
+				if !ok {
+					return
+				}
+
+				slots := strings.TrimSpace(slotsEntry.Text)
+				if slots != "" {
+					if num, err := strconv.Atoi(slots); err != nil || num < 0 {
+						dialog.ShowError(fmt.Errorf("concurrent transfers must be a non-negative number"), mv.window)
+						return
+					}
+				}
+
+				updated := make(fs.EnvConfig, len(values)+1)
+				for key, value := range values {
+					updated[key] = value
+				}
+
+				updated[fs.TransferSlots] = slots
+
+				if err := fs.SaveConfig(updated); err != nil {
+					dialog.ShowError(err, mv.window)
+					return
+				}
+
+				mv.configValues = updated
+				mv.transferScheduler.SetLimit(transferSlotsFromConfig(updated))
+			},
+			mv.window,
+		)
+		dialogWindow.Resize(fyne.NewSize(500, 200))
+		dialogWindow.Show()
+	})
+
+	return stationBtn
+}
+
+func transferSlotsFromConfig(values fs.EnvConfig) int {
+	raw := strings.TrimSpace(values[fs.TransferSlots])
+	if raw == "" {
+		return install.DefaultTransferSlots
+	}
+	num, err := strconv.Atoi(raw)
+	if err != nil || num < 0 {
+		return install.DefaultTransferSlots
+	}
+	return num
+}
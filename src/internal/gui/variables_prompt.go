@@ -0,0 +1,73 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"wago-init/internal/fs"
+	"wago-init/internal/install"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+func BuildVariablesPrompt(configValues *fs.EnvConfig, w fyne.Window) *widget.Button {
+	variablesBtn := widget.NewButton("Device variables", func() {
+		values := fs.EnvConfig{}
+		if configValues != nil && *configValues != nil {
+			values = *configValues
+		}
+
+		variablesEntry := widget.NewMultiLineEntry()
+		variablesEntry.SetText(fs.DecodeMultilineValue(values[fs.DeviceVariables]))
+		variablesEntry.SetPlaceHolder("SITE=plant-7\n0054F7A1B2C3 DEVICE_ROLE=gateway\n00:30:de:12:34:56 DEVICE_ROLE=sensor")
+		variablesEntry.SetMinRowsVisible(12)
+
+		help := widget.NewLabel(fmt.Sprintf(
+			"Use ${NAME} in container flags and image references. Built-in: ${%s}, ${%s}, ${%s}, ${%s}, ${%s}.\n"+
+				"Custom variables: NAME=value for every device, or <serial|MAC> NAME=value for one device.",
+			install.VariableSerial, install.VariableMAC, install.VariableIP, install.VariableHostname, install.VariableFirmwareBuild))
+		help.Wrapping = fyne.TextWrapWord
+
+		content := container.NewBorder(help, nil, nil, nil, variablesEntry)
+
+		dialogWindow := dialog.NewCustomConfirm(
+			"Device Variables",
+			"Save",
+			"Cancel",
+			content,
+			func(ok bool) {
+				if !ok {
+					return
+				}
+
+				raw := strings.TrimSpace(variablesEntry.Text)
+				if _, err := install.ParseVariableDefinitions(raw); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+
+				updated := make(fs.EnvConfig, len(values)+1)
+				for key, value := range values {
+					updated[key] = value
+				}
+				updated[fs.DeviceVariables] = fs.EncodeMultilineValue(raw)
+
+				if err := fs.SaveConfig(updated); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+
+				if configValues != nil {
+					*configValues = updated
+				}
+			},
+			w,
+		)
+		dialogWindow.Resize(fyne.NewSize(800, 500))
+		dialogWindow.Show()
+	})
+
+	return variablesBtn
+}
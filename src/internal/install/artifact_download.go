@@ -0,0 +1,35 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const artifactDownloadTimeout = 20 * time.Minute
+
+// downloadFromArtifactServer publishes localPath on the station's artifact server and lets the device
+// fetch it into remoteDir with wget. The download token is revoked once the transfer has finished.
+func downloadFromArtifactServer(ctx context.Context, client *ssh.Client, server ArtifactServer, deviceIP, localPath, remoteDir string, logFn func(string, string)) error {
+	url, release, err := server.Publish(localPath, deviceIP)
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", filepath.Base(localPath), err)
+	}
+	defer release()
+
+	remoteFile := path.Join(remoteDir, filepath.Base(localPath))
+	logFn(fmt.Sprintf("Device is downloading %s from station", filepath.Base(localPath)), "")
+
+	cmd := fmt.Sprintf("mkdir -p %s && wget -q -O %s %s", shellQuote(remoteDir), shellQuote(remoteFile), shellQuote(url))
+	if err := runSSHCommandStreamingContext(ctx, client, cmd, artifactDownloadTimeout, logFn); err != nil {
+		_, _ = runSSHCommand(client, fmt.Sprintf("rm -f %s", shellQuote(remoteFile)), shortSessionTimeout)
+		return fmt.Errorf("wget: %w", err)
+	}
+
+	logFn("Download complete.", "")
+	return nil
+}
@@ -0,0 +1,100 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const composeRemoteDir = "/home/wago-init/compose"
+
+// DeployCompose copies the compose file (and optional env file) to the device and brings the project up
+// with "docker compose", falling back to the standalone docker-compose binary. An optional project folder
+// is synced into the same directory first, so relative build contexts and bind mounts resolve. Nothing in
+// the directory is removed, so data the containers keep in relative bind mounts survives a redeploy.
+func DeployCompose(client *ssh.Client, logFn func(string, string), params Parameters) error {
+	composeFile := strings.TrimSpace(params.ComposeFile)
+	if composeFile == "" {
+		return errors.New("compose file is not configured")
+	}
+	envFile := strings.TrimSpace(params.ComposeEnvFile)
+	projectDir := strings.TrimSpace(params.ComposeProjectDir)
+	if params.ImageSource == ImageSourceStation {
+		return errors.New("station pull is not supported in compose mode; use an image tarball instead")
+	}
+	offline := offlineImageSource(params.ImageSource)
+
+	composeCmd, err := detectComposeCommand(params.Context, client)
+	if err != nil {
+		return err
+	}
+	logFn("Using "+composeCmd+" for deployment", "")
+
+	err = withTransferSlot(&params, "compose copy", logFn, func() error {
+		if projectDir != "" {
+			if err := CopyPathToDevice(client, params.Context, projectDir, composeRemoteDir, CopyOptions{Sync: true}, logFn); err != nil {
+				return fmt.Errorf("copy compose project folder: %w", err)
+			}
+		}
+		for _, localPath := range []string{composeFile, envFile} {
+			if localPath == "" {
+				continue
+			}
+			if err := CopyPathToDevice(client, params.Context, localPath, composeRemoteDir, CopyOptions{}, logFn); err != nil {
+				return fmt.Errorf("copy %s: %w", filepath.Base(localPath), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if offline {
+		if err := LoadImagesOffline(client, logFn, params); err != nil {
+			return err
+		}
+	} else {
+		registryLogin(client, params.Registry, logFn)
+	}
+
+	baseCmd := fmt.Sprintf("cd %s && %s -f %s", shellQuote(composeRemoteDir), composeCmd, shellQuote(filepath.Base(composeFile)))
+	if envFile != "" {
+		baseCmd += " --env-file " + shellQuote(filepath.Base(envFile))
+	}
+
+	if !offline {
+		logFn("Pulling compose images", "")
+		if err := runSSHCommandStreamingContext(params.Context, client, baseCmd+" pull", containerCreateTimeout, logFn); err != nil {
+			return fmt.Errorf("compose pull failed: %w", err)
+		}
+	}
+
+	upCmd := baseCmd + " up -d"
+	if offline {
+		upCmd += " --pull never"
+	}
+	logFn("Starting compose project", "")
+	if err := runSSHCommandStreamingContext(params.Context, client, upCmd, containerCreateTimeout, logFn); err != nil {
+		return fmt.Errorf("compose up failed: %w", err)
+	}
+
+	logFn("Compose project started successfully.", "")
+	return nil
+}
+
+func detectComposeCommand(ctx context.Context, client *ssh.Client) (string, error) {
+	for _, candidate := range []string{"docker compose", "docker-compose"} {
+		if _, err := runSSHCommandContext(ctx, client, candidate+" version", shortSessionTimeout); err == nil {
+			return candidate, nil
+		}
+		if err := checkCancellation(ctx); err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("neither 'docker compose' nor 'docker-compose' is available on the device")
+}
@@ -2,37 +2,189 @@ package install
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 	"wago-init/internal/fs"
+	"wago-init/internal/registry"
 
 	"golang.org/x/crypto/ssh"
 )
 
 const (
 	containerCreateTimeout = 20 * time.Minute
+
+	// Session progress range covered by the image pulls of docker create.
+	containerProgressStart = 0.65
+	containerProgressEnd   = 0.95
 )
 
-var privateLogFn func(string, string)
+var containerIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Container deployment modes selectable in the container settings.
+const (
+	ContainerModeCreate  = "create"
+	ContainerModeCompose = "compose"
+)
+
+// ContainerDefinition describes one container to create on the device. Containers are handled in
+// ascending StartOrder; definitions with the same order keep their configured sequence.
+type ContainerDefinition struct {
+	Name         string
+	Image        string
+	Flags        string
+	StartOrder   int
+	WaitHealthy  bool
+	PinnedDigest string
+}
+
+// ContainerDefinitionsFromConfig converts stored container settings into definitions with joined flags.
+func ContainerDefinitionsFromConfig(configs []fs.ContainerConfig) []ContainerDefinition {
+	definitions := make([]ContainerDefinition, 0, len(configs))
+	for _, cfg := range configs {
+		definitions = append(definitions, ContainerDefinition{
+			Name:         strings.TrimSpace(cfg.Name),
+			Image:        strings.TrimSpace(cfg.Image),
+			Flags:        BuildContainerCommand(cfg.Command),
+			StartOrder:   cfg.StartOrder,
+			WaitHealthy:  cfg.WaitHealthy,
+			PinnedDigest: strings.TrimSpace(cfg.Digest),
+		})
+	}
+	return definitions
+}
+
+func CreateContainer(client *ssh.Client, logFn func(string, string), params Parameters, progressFn func(float64, float64)) error {
+	if params.ContainerMode == ContainerModeCompose {
+		return DeployCompose(client, logFn, params)
+	}
 
-func CreateContainer(client *ssh.Client, logFn func(string, string), params Parameters) error {
+	containers := sortedContainers(params.Containers)
+	if len(containers) == 0 {
+		logFn("No containers configured, skipping container creation.", "")
+		return nil
+	}
+
+	vars := params.Device.Variables(params.Variables)
+	logDeviceVariables(vars, logFn)
+	containers, err := applyDeviceVariables(containers, vars, logFn)
+	if err != nil {
+		return err
+	}
+	for _, definition := range containers {
+		if err := ValidateContainerCommand(definition.Flags); err != nil {
+			return fmt.Errorf("container %s: %w", containerLabel(definition), err)
+		}
+	}
+	params.Containers = containers
 
-	privateLogFn = logFn
+	if err := prepareImages(client, logFn, params); err != nil {
+		return err
+	}
 
-	ecrLogin(client, params.AWSToken, params.AWSEcrUrl)
+	refs := make([]string, len(containers))
+	step := (containerProgressEnd - containerProgressStart) / float64(len(containers))
+	for i, definition := range containers {
+		if err := checkCancellation(params.Context); err != nil {
+			return err
+		}
+		start := containerProgressStart + step*float64(i)
+		pull := newPullProgress(containerLogger(logFn, definition), progressFn, start, start+step)
+		ref, err := createSingleContainer(client, logFn, definition, offlineImageSource(params.ImageSource), pull)
+		if err != nil {
+			return err
+		}
+		refs[i] = ref
+	}
 
-	createCmd := buildDockerCreateCommand(params.ContainerFlags, params.ContainerImage)
-	logFn("Creating container with image: "+params.ContainerImage, "")
-	if err := runSSHCommandStreaming(client, createCmd, containerCreateTimeout, handleContainerPullLogging); err != nil {
-		return fmt.Errorf("docker create failed: %w", err)
+	for i, definition := range containers {
+		if err := startContainer(params.Context, client, containerLogger(logFn, definition), definition, refs[i]); err != nil {
+			return err
+		}
 	}
 
-	logFn("Container created successfully.", "")
 	return nil
 }
 
-func handleContainerPullLogging(line string, _ string) {
-	privateLogFn(line, line[:12])
+// prepareImages makes the images available to docker on the device: either by logging in to the registry
+// so docker create can pull them, or by loading them offline.
+func prepareImages(client *ssh.Client, logFn func(string, string), params Parameters) error {
+	if !offlineImageSource(params.ImageSource) {
+		registryLogin(client, params.Registry, logFn)
+		return nil
+	}
+	return LoadImagesOffline(client, logFn, params)
+}
+
+// createSingleContainer runs docker create and returns a reference (name or ID) to the new container.
+// Pull output printed by docker create for a missing image is passed to pull. With offline set, the image
+// must already be loaded on the device and docker create does not pull it.
+func createSingleContainer(client *ssh.Client, logFn func(string, string), definition ContainerDefinition, offline bool, pull *pullProgress) (string, error) {
+	label := containerLabel(definition)
+	containerLogFn := containerLogger(logFn, definition)
+
+	if definition.Image == "" {
+		return "", fmt.Errorf("container %s has no image configured", label)
+	}
+
+	var containerID string
+	captureFn := func(line, replaceIdentifier string) {
+		if containerIDPattern.MatchString(line) {
+			containerID = line
+			return
+		}
+		pull.Handle(line, replaceIdentifier)
+	}
+
+	createCmd := buildDockerCreateCommand(definition.Name, definition.Flags, definition.Image, offline)
+	containerLogFn("Creating container with image: "+definition.Image, "")
+	if err := runSSHCommandStreaming(client, createCmd, containerCreateTimeout, captureFn); err != nil {
+		return "", fmt.Errorf("docker create for %s failed: %w", label, err)
+	}
+
+	containerLogFn("Container created successfully.", "")
+
+	ref := containerID
+	if ref == "" {
+		ref = definition.Name
+	}
+	if ref == "" {
+		return "", fmt.Errorf("could not determine id of container %s", label)
+	}
+
+	if err := verifyImageDigest(client, containerLogFn, definition); err != nil {
+		_, _ = runSSHCommand(client, "docker rm -f "+shellQuote(ref), longSessionTimeout)
+		return "", fmt.Errorf("container %s: %w", label, err)
+	}
+
+	return ref, nil
+}
+
+func containerLogger(logFn func(string, string), definition ContainerDefinition) func(string, string) {
+	label := containerLabel(definition)
+	return func(line, replaceIdentifier string) {
+		if replaceIdentifier != "" {
+			replaceIdentifier = "[" + label + "] " + replaceIdentifier
+		}
+		logFn("["+label+"] "+line, replaceIdentifier)
+	}
+}
+
+func sortedContainers(containers []ContainerDefinition) []ContainerDefinition {
+	sorted := make([]ContainerDefinition, len(containers))
+	copy(sorted, containers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartOrder < sorted[j].StartOrder
+	})
+	return sorted
+}
+
+func containerLabel(definition ContainerDefinition) string {
+	if definition.Name != "" {
+		return definition.Name
+	}
+	return definition.Image
 }
 
 func BuildContainerCommand(flagsRaw string) string {
@@ -60,17 +212,30 @@ func BuildContainerCommand(flagsRaw string) string {
 	return strings.Join(parts, " ")
 }
 
-func ecrLogin(client *ssh.Client, token, ecrUrl string) error {
-	loginCmd := fmt.Sprintf("echo %s | docker login --username AWS --password-stdin %s",
-		shellQuote(token), shellQuote(ecrUrl))
+// registryLogin logs docker on the device in to the registry. A failed login is only logged because
+// public images can still be pulled without it.
+func registryLogin(client *ssh.Client, creds registry.Credentials, logFn func(string, string)) {
+	if creds.Anonymous() {
+		return
+	}
+	loginCmd := fmt.Sprintf("echo %s | docker login --username %s --password-stdin",
+		shellQuote(creds.Password), shellQuote(creds.Username))
+	if creds.Server != "" {
+		loginCmd += " " + shellQuote(creds.Server)
+	}
 	if _, err := runSSHCommand(client, loginCmd, shortSessionTimeout); err != nil {
-		return fmt.Errorf("ecr login failed: %w", err)
+		logFn("Registry login failed: "+err.Error(), "")
 	}
-	return nil
 }
 
-func buildDockerCreateCommand(flags, image string) string {
-	parts := []string{"docker", "create"}
+func buildDockerCreateCommand(name, flags, image string, offline bool) string {
+	parts := []string{"docker", "create", "--label", ManagedContainerLabel}
+	if offline {
+		parts = append(parts, "--pull", "never")
+	}
+	if name != "" && !hasNameFlag(flags) {
+		parts = append(parts, "--name", shellQuote(name))
+	}
 	trimmedFlags := strings.TrimSpace(flags)
 	if trimmedFlags != "" {
 		parts = append(parts, trimmedFlags)
@@ -78,3 +243,12 @@ func buildDockerCreateCommand(flags, image string) string {
 	parts = append(parts, shellQuote(image))
 	return strings.Join(parts, " ")
 }
+
+func hasNameFlag(flags string) bool {
+	for _, field := range strings.Fields(flags) {
+		if field == "--name" || strings.HasPrefix(field, "--name=") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,371 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ContainerFlags is the structured form of the docker create flags stored in CONTAINER_COMMAND.
+// Flags without a dedicated field are kept verbatim in Extra, one argument group per entry, in shell
+// syntax. All other fields hold plain values.
+type ContainerFlags struct {
+	Name       string
+	Restart    string
+	Ports      []string
+	Volumes    []string
+	Env        []string
+	Devices    []string
+	Network    string
+	Privileged bool
+	Extra      []string
+
+	// sources records the shell word every value was written as, so Format reproduces the original
+	// quoting, and with it whether the device shell expands $VAR, for unchanged values.
+	sources map[flagPosition]shellWord
+}
+
+// flagPosition identifies a parsed value: the flag as Format writes it and the index among its values.
+type flagPosition struct {
+	flag  string
+	index int
+}
+
+var (
+	containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	restartPattern       = regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`)
+	portPattern          = regexp.MustCompile(`^(?:(\d{1,3}(?:\.\d{1,3}){3}):)?(?:(\d+(?:-\d+)?)?:)?(\d+(?:-\d+)?)(?:/(tcp|udp|sctp))?$`)
+	envKeyPattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	networkPattern       = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.:-]*$`)
+	safeShellWordPattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+)
+
+// ParseContainerFlags converts the multiline flags text into its structured form. Unknown flags and
+// their values end up in Extra so that Format reproduces them unchanged.
+func ParseContainerFlags(raw string) (ContainerFlags, error) {
+	var flags ContainerFlags
+
+	words, err := splitShellWords(strings.ReplaceAll(raw, "\r\n", "\n"))
+	if err != nil {
+		return flags, err
+	}
+	flags.sources = make(map[flagPosition]shellWord)
+
+	for i := 0; i < len(words); i++ {
+		word := words[i].value
+		key, inlineValue, hasInline := strings.Cut(word, "=")
+		if !strings.HasPrefix(word, "--") {
+			key, hasInline = word, false
+		}
+
+		// takeValue returns the value of the current flag, which Format writes as flag, and records
+		// its source at position index.
+		takeValue := func(flag string, index int) (string, error) {
+			position := flagPosition{flag: flag, index: index}
+			if hasInline {
+				// The source can only be split when the flag itself is written unquoted.
+				if source, ok := strings.CutPrefix(words[i].source, key+"="); ok {
+					flags.sources[position] = shellWord{value: inlineValue, source: source}
+				}
+				return inlineValue, nil
+			}
+			if i+1 >= len(words) {
+				return "", fmt.Errorf("flag %s needs a value", key)
+			}
+			i++
+			flags.sources[position] = words[i]
+			return words[i].value, nil
+		}
+
+		switch key {
+		case "--name":
+			if flags.Name, err = takeValue("--name", 0); err != nil {
+				return flags, err
+			}
+		case "--restart":
+			if flags.Restart, err = takeValue("--restart", 0); err != nil {
+				return flags, err
+			}
+		case "-p", "--publish":
+			value, err := takeValue("-p", len(flags.Ports))
+			if err != nil {
+				return flags, err
+			}
+			flags.Ports = append(flags.Ports, value)
+		case "-v", "--volume":
+			value, err := takeValue("-v", len(flags.Volumes))
+			if err != nil {
+				return flags, err
+			}
+			flags.Volumes = append(flags.Volumes, value)
+		case "-e", "--env":
+			value, err := takeValue("-e", len(flags.Env))
+			if err != nil {
+				return flags, err
+			}
+			flags.Env = append(flags.Env, value)
+		case "--device":
+			value, err := takeValue("--device", len(flags.Devices))
+			if err != nil {
+				return flags, err
+			}
+			flags.Devices = append(flags.Devices, value)
+		case "--network", "--net":
+			if flags.Network, err = takeValue("--network", 0); err != nil {
+				return flags, err
+			}
+		case "--privileged":
+			flags.Privileged = !hasInline || inlineValue == "true"
+		default:
+			// Without knowing the flag it is unclear whether it takes a value; the next word is taken
+			// as its value unless it is a flag itself.
+			group := []string{words[i].source}
+			if !hasInline && strings.HasPrefix(word, "-") && i+1 < len(words) && !strings.HasPrefix(words[i+1].value, "-") {
+				i++
+				group = append(group, words[i].source)
+			}
+			flags.Extra = append(flags.Extra, strings.Join(group, " "))
+		}
+	}
+
+	return flags, nil
+}
+
+// KeepQuoting returns f with the original quoting recorded when parsing original, so values the user
+// did not change are formatted exactly as they were written.
+func (f ContainerFlags) KeepQuoting(original ContainerFlags) ContainerFlags {
+	f.sources = original.sources
+	return f
+}
+
+// Format renders the flags in the multiline CONTAINER_COMMAND format, one flag per line. Values keep
+// the quoting they were parsed with; new values are quoted so the device shell takes them literally.
+func (f ContainerFlags) Format() string {
+	var lines []string
+	add := func(flag string, index int, value string) {
+		lines = append(lines, flag+" "+f.sourceOf(flag, index, value))
+	}
+
+	if f.Name != "" {
+		add("--name", 0, f.Name)
+	}
+	if f.Restart != "" {
+		add("--restart", 0, f.Restart)
+	}
+	if f.Network != "" {
+		add("--network", 0, f.Network)
+	}
+	if f.Privileged {
+		lines = append(lines, "--privileged")
+	}
+	for i, port := range f.Ports {
+		add("-p", i, port)
+	}
+	for i, volume := range f.Volumes {
+		add("-v", i, volume)
+	}
+	for i, env := range f.Env {
+		add("-e", i, env)
+	}
+	for i, device := range f.Devices {
+		add("--device", i, device)
+	}
+	lines = append(lines, f.Extra...)
+
+	return strings.Join(lines, "\n")
+}
+
+// sourceOf returns the shell word for value at position index of flag. The recorded source is used when
+// the value at that position is unchanged, otherwise the source of an equal value of the same flag, so
+// removing an entry keeps the quoting of the others. New values are quoted.
+func (f ContainerFlags) sourceOf(flag string, index int, value string) string {
+	if word, ok := f.sources[flagPosition{flag: flag, index: index}]; ok && word.value == value {
+		return word.source
+	}
+	for position, word := range f.sources {
+		if position.flag == flag && word.value == value {
+			return word.source
+		}
+	}
+	return quoteShellWord(value)
+}
+
+// Validate checks every field and returns all problems found, joined into one error. Values containing
+// ${NAME} device variables are only checked where the variable cannot affect the result.
+func (f ContainerFlags) Validate() error {
+	var problems []error
+
+	if f.Name != "" && !hasVariables(f.Name) && !containerNamePattern.MatchString(f.Name) {
+		problems = append(problems, fmt.Errorf("invalid container name '%s'", f.Name))
+	}
+	if f.Restart != "" && !hasVariables(f.Restart) && !restartPattern.MatchString(f.Restart) {
+		problems = append(problems, fmt.Errorf("invalid restart policy '%s' (use no, always, unless-stopped or on-failure[:N])", f.Restart))
+	}
+	if f.Network != "" && !hasVariables(f.Network) && !networkPattern.MatchString(f.Network) {
+		problems = append(problems, fmt.Errorf("invalid network mode '%s'", f.Network))
+	}
+	for _, port := range f.Ports {
+		if hasVariables(port) {
+			continue
+		}
+		if err := validatePortMapping(port); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	for _, volume := range f.Volumes {
+		if hasVariables(volume) {
+			continue
+		}
+		if err := validateVolume(volume); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	for _, env := range f.Env {
+		key, _, _ := strings.Cut(env, "=")
+		if !envKeyPattern.MatchString(key) {
+			problems = append(problems, fmt.Errorf("invalid environment variable '%s'", env))
+		}
+	}
+	for _, device := range f.Devices {
+		if !strings.HasPrefix(device, "/dev/") {
+			problems = append(problems, fmt.Errorf("invalid device '%s' (expected /dev/...)", device))
+		}
+	}
+	for _, extra := range f.Extra {
+		if !strings.HasPrefix(extra, "-") {
+			problems = append(problems, fmt.Errorf("unexpected argument '%s'", extra))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// ValidateContainerCommand parses and validates a stored CONTAINER_COMMAND value.
+func ValidateContainerCommand(flagsRaw string) error {
+	flags, err := ParseContainerFlags(BuildContainerCommand(flagsRaw))
+	if err != nil {
+		return err
+	}
+	return flags.Validate()
+}
+
+func validatePortMapping(port string) error {
+	m := portPattern.FindStringSubmatch(port)
+	if m == nil {
+		return fmt.Errorf("invalid port mapping '%s' (expected [ip:][host:]container[/tcp|udp])", port)
+	}
+	for _, group := range []string{m[2], m[3]} {
+		for _, part := range strings.Split(group, "-") {
+			if part == "" {
+				continue
+			}
+			num, err := strconv.Atoi(part)
+			if err != nil || num < 1 || num > 65535 {
+				return fmt.Errorf("invalid port number '%s' in '%s'", part, port)
+			}
+		}
+	}
+	return nil
+}
+
+func validateVolume(volume string) error {
+	parts := strings.Split(volume, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return fmt.Errorf("invalid volume '%s' (expected source:/target[:options])", volume)
+	}
+	target := parts[0]
+	if len(parts) >= 2 {
+		target = parts[1]
+	}
+	if !strings.HasPrefix(target, "/") {
+		return fmt.Errorf("invalid volume '%s': container path must be absolute", volume)
+	}
+	return nil
+}
+
+// shellWord is a word of the flags text: its value after quote removal and its source text.
+type shellWord struct {
+	value  string
+	source string
+}
+
+// splitShellWords splits text into words following POSIX shell quoting rules for single quotes,
+// double quotes and backslashes. Inside double quotes a backslash only escapes $, `, ", \ and newline
+// and is kept literally before any other character. A backslash-newline joins lines.
+func splitShellWords(text string) ([]shellWord, error) {
+	var (
+		words   []shellWord
+		current strings.Builder
+		inWord  bool
+		start   int
+		quote   rune
+		escaped bool
+	)
+
+	for i, r := range text {
+		if !inWord && quote == 0 && !escaped {
+			start = i
+		}
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				current.WriteRune('\\')
+			}
+			if r != '\n' {
+				current.WriteRune(r)
+			} else if quote == 0 && i-start == 1 {
+				// A line continuation between words does not start a word.
+				inWord = false
+			}
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, shellWord{value: current.String(), source: text[start:i]})
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in container flags")
+	}
+	if inWord {
+		words = append(words, shellWord{value: current.String(), source: text[start:]})
+	}
+	return words, nil
+}
+
+// quoteShellWord quotes word so the device shell takes it literally.
+func quoteShellWord(word string) string {
+	if safeShellWordPattern.MatchString(word) {
+		return word
+	}
+	return shellQuote(word)
+}
@@ -0,0 +1,101 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContainerFlagsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want ContainerFlags
+	}{
+		{
+			name: "dedicated flags",
+			raw:  "--name app\n--restart unless-stopped\n--network host\n--privileged\n-p 8080:80\n-v /data:/data\n-e MODE=prod\n--device /dev/ttyS0",
+			want: ContainerFlags{
+				Name:       "app",
+				Restart:    "unless-stopped",
+				Network:    "host",
+				Privileged: true,
+				Ports:      []string{"8080:80"},
+				Volumes:    []string{"/data:/data"},
+				Env:        []string{"MODE=prod"},
+				Devices:    []string{"/dev/ttyS0"},
+			},
+		},
+		{
+			name: "backslash in double quotes is literal",
+			raw:  `-v "C:\data:/data"`,
+			want: ContainerFlags{Volumes: []string{`C:\data:/data`}},
+		},
+		{
+			name: "backslash escapes in double quotes",
+			raw:  `-e "MSG=say \"hi\" for \$5 \\ done"`,
+			want: ContainerFlags{Env: []string{`MSG=say "hi" for $5 \ done`}},
+		},
+		{
+			name: "same value with different quoting",
+			raw:  "-e \"HOME_DIR=$HOME\"\n-e 'HOME_DIR=$HOME'",
+			want: ContainerFlags{Env: []string{"HOME_DIR=$HOME", "HOME_DIR=$HOME"}},
+		},
+		{
+			name: "inline values",
+			raw:  "--env=A=B\n\"--env=C=D\"\n--volume=\"/a b:/c\"",
+			want: ContainerFlags{Env: []string{"A=B", "C=D"}, Volumes: []string{"/a b:/c"}},
+		},
+		{
+			name: "unknown boolean flags",
+			raw:  "--init --rm\n--log-opt max-size=10m extra",
+			want: ContainerFlags{Extra: []string{"--init", "--rm", "--log-opt max-size=10m", "extra"}},
+		},
+		{
+			name: "line continuation",
+			raw:  "--name app \\\n  -p 80:80",
+			want: ContainerFlags{Name: "app", Ports: []string{"80:80"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseContainerFlags(tt.raw)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := withoutSources(parsed); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parse = %#v, want %#v", got, tt.want)
+			}
+
+			formatted := parsed.Format()
+			reparsed, err := ParseContainerFlags(formatted)
+			if err != nil {
+				t.Fatalf("parse formatted %q: %v", formatted, err)
+			}
+			if got := withoutSources(reparsed); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parse(format) = %#v, want %#v (formatted %q)", got, tt.want, formatted)
+			}
+			if again := reparsed.Format(); again != formatted {
+				t.Fatalf("format is not stable: %q, then %q", formatted, again)
+			}
+		})
+	}
+}
+
+func TestContainerFlagsKeepQuoting(t *testing.T) {
+	original, err := ParseContainerFlags("-e \"A=$HOME\"\n-e 'B=$HOME'\n-e C=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := ContainerFlags{Env: []string{"B=$HOME", "D=$HOME"}}.KeepQuoting(original)
+	want := "-e 'B=$HOME'\n-e 'D=$HOME'"
+	if got := edited.Format(); got != want {
+		t.Fatalf("format = %q, want %q", got, want)
+	}
+}
+
+func withoutSources(flags ContainerFlags) ContainerFlags {
+	flags.sources = nil
+	return flags
+}
@@ -0,0 +1,121 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	containerRunningTimeout = 1 * time.Minute
+	containerHealthyTimeout = 5 * time.Minute
+	containerPollInterval   = 2 * time.Second
+	containerLogTailLines   = 50
+)
+
+// startContainer starts a created container, waits until it is running and, if requested, until its
+// Docker HEALTHCHECK reports healthy. On failure the last log lines of the container are logged.
+func startContainer(ctx context.Context, client *ssh.Client, logFn func(string, string), definition ContainerDefinition, ref string) error {
+	logFn("Starting container", "")
+	if _, err := runSSHCommandContext(ctx, client, "docker start "+shellQuote(ref), longSessionTimeout); err != nil {
+		logContainerTail(client, logFn, ref)
+		return fmt.Errorf("docker start for %s failed: %w", containerLabel(definition), err)
+	}
+
+	if err := waitForContainer(ctx, client, logFn, ref, definition.WaitHealthy); err != nil {
+		if ctx.Err() == nil {
+			logContainerTail(client, logFn, ref)
+		}
+		return fmt.Errorf("container %s: %w", containerLabel(definition), err)
+	}
+
+	return nil
+}
+
+func waitForContainer(parent context.Context, client *ssh.Client, logFn func(string, string), ref string, waitHealthy bool) error {
+	runningCtx, cancel := context.WithTimeout(contextOrBackground(parent), containerRunningTimeout)
+	defer cancel()
+
+	for {
+		state, _, err := inspectContainerState(runningCtx, client, ref)
+		if err != nil {
+			return timeoutError(parent, "not running", containerRunningTimeout, err)
+		}
+		if state == "running" {
+			logFn("Container is running", "")
+			break
+		}
+		if state == "exited" || state == "dead" {
+			return fmt.Errorf("stopped unexpectedly (state %s)", state)
+		}
+		if err := sleepWithContext(runningCtx, containerPollInterval); err != nil {
+			return timeoutError(parent, "not running", containerRunningTimeout, err)
+		}
+	}
+
+	if !waitHealthy {
+		return nil
+	}
+
+	healthyCtx, cancelHealthy := context.WithTimeout(contextOrBackground(parent), containerHealthyTimeout)
+	defer cancelHealthy()
+
+	for {
+		state, health, err := inspectContainerState(healthyCtx, client, ref)
+		if err != nil {
+			return timeoutError(parent, "not healthy", containerHealthyTimeout, err)
+		}
+		switch {
+		case health == "":
+			logFn("Image defines no HEALTHCHECK, skipping health wait", "")
+			return nil
+		case health == "healthy":
+			logFn("Container is healthy", "")
+			return nil
+		case health == "unhealthy":
+			return errors.New("health check reports unhealthy")
+		case state != "running":
+			return fmt.Errorf("stopped unexpectedly (state %s)", state)
+		}
+		logFn("Waiting for container to become healthy (status: "+health+")", "Waiting for container to become healthy")
+		if err := sleepWithContext(healthyCtx, containerPollInterval); err != nil {
+			return timeoutError(parent, "not healthy", containerHealthyTimeout, err)
+		}
+	}
+}
+
+// inspectContainerState returns the container state and, if the image defines a HEALTHCHECK, its health status.
+func inspectContainerState(ctx context.Context, client *ssh.Client, ref string) (string, string, error) {
+	cmd := fmt.Sprintf("docker inspect -f %s %s",
+		shellQuote("{{.State.Status}}|{{if .State.Health}}{{.State.Health.Status}}{{end}}"), shellQuote(ref))
+	output, err := runSSHCommandContext(ctx, client, cmd, shortSessionTimeout)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.SplitN(strings.TrimSpace(output), "|", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("unexpected inspect output: %s", output)
+	}
+	return parts[0], parts[1], nil
+}
+
+func logContainerTail(client *ssh.Client, logFn func(string, string), ref string) {
+	cmd := fmt.Sprintf("docker logs --tail %d %s 2>&1", containerLogTailLines, shellQuote(ref))
+	output, err := runSSHCommand(client, cmd, shortSessionTimeout)
+	if err != nil {
+		logFn("Could not read container logs: "+err.Error(), "")
+		return
+	}
+	if strings.TrimSpace(output) == "" {
+		logFn("Container produced no log output", "")
+		return
+	}
+	logFn(fmt.Sprintf("Last %d container log lines:", containerLogTailLines), "")
+	for _, line := range strings.Split(output, "\n") {
+		logFn("  "+line, "")
+	}
+}
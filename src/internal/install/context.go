@@ -1,6 +1,11 @@
 package install
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
 
 func checkCancellation(ctx context.Context) error {
 	if ctx == nil {
@@ -13,3 +18,35 @@ func checkCancellation(ctx context.Context) error {
 		return nil
 	}
 }
+
+func contextOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// timeoutError turns the deadline of a wait into "<what> within <timeout>" while passing through
+// cancellation of the parent context unchanged. Other errors are returned as they are.
+func timeoutError(parent context.Context, what string, timeout time.Duration, err error) error {
+	if parentErr := checkCancellation(parent); parentErr != nil {
+		return parentErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s within %s", what, timeout)
+	}
+	return err
+}
+
+// sleepWithContext pauses for the given duration or until ctx is done, whichever happens first.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	ctx = contextOrBackground(ctx)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
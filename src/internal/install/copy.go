@@ -4,6 +4,8 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -15,12 +17,46 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
-const copyToDeviceTimeout = 20 * time.Minute
+const (
+	copyToDeviceTimeout = 20 * time.Minute
+	// Number of paths passed to one chown or sha256sum call on the device.
+	remoteBatchSize = 200
+)
+
+// CopyOptions controls how CopyPathToDevice transfers the local content.
+type CopyOptions struct {
+	Templates    bool
+	TemplateData TemplateData
+	// Sync transfers only new and changed files. SyncDelete additionally removes remote files that are
+	// missing locally, and DryRun only logs the planned changes.
+	Sync       bool
+	SyncDelete bool
+	DryRun     bool
+	// Owner and Group are applied to every copied entry; FileMode and DirMode replace the local
+	// permissions when non-zero.
+	Owner    string
+	Group    string
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	// Excludes holds gitignore-style patterns applied in addition to .wagoignore files.
+	Excludes []string
+	// Compress sends the stream gzip-compressed if the device tar supports it. CompressionLevel ranges
+	// from 1 (fastest) to 9 (smallest); other values use the gzip default.
+	Compress         bool
+	CompressionLevel int
+	// Progress receives the completed fraction of the transfer.
+	Progress func(float64)
+	// BackupName enables the backup of remote files the copy replaces into that archive below
+	// ConfigBackupDir (see backupRemoteFiles).
+	BackupName string
+}
 
 // CopyPathToDevice replicates the contents of localPath onto remotePath using an existing SSH client.
 // localPath can point to either a single file or a directory. Directories are copied recursively.
-// Collected output is streamed through logFn so the user can monitor progress.
-func CopyPathToDevice(client *ssh.Client, ctx context.Context, localPath, remotePath string, logFn func(string, string)) error {
+// Collected output is streamed through logFn so the user can monitor progress. With opts.Templates set,
+// *.tmpl files are rendered on the station and copied without the extension. With opts.Sync set, only
+// files that differ from the device are transferred (see syncPathToDevice).
+func CopyPathToDevice(client *ssh.Client, ctx context.Context, localPath, remotePath string, opts CopyOptions, logFn func(string, string)) error {
 	if err := checkCancellation(ctx); err != nil {
 		return err
 	}
@@ -35,12 +71,68 @@ func CopyPathToDevice(client *ssh.Client, ctx context.Context, localPath, remote
 		return fmt.Errorf("stat local path: %w", err)
 	}
 
-	logFn(fmt.Sprintf("Copying %s to %s", localPath, remotePath), "")
+	var rendered map[string][]byte
+	if opts.Templates {
+		if rendered, err = renderTemplates(ctx, localPath, info, opts.TemplateData, opts.Excludes, logFn); err != nil {
+			return err
+		}
+	}
 
 	if _, err := runSSHCommand(client, fmt.Sprintf("mkdir -p %s", shellQuote(remotePath)), shortSessionTimeout); err != nil {
 		return fmt.Errorf("ensure remote directory: %w", err)
 	}
 
+	if opts.Sync {
+		return syncPathToDevice(client, ctx, localPath, remotePath, info, rendered, opts, logFn)
+	}
+
+	logFn(fmt.Sprintf("Copying %s to %s", localPath, remotePath), "")
+
+	discard := func(string, string) {}
+	var total int64
+	var replaced []backupEntry
+	err = walkCopySource(ctx, localPath, info, opts.Excludes, discard, func(fullPath, rel string, fileInfo os.FileInfo) error {
+		total += tarEntrySize(fullPath, fileInfo, rendered)
+		replaced = append(replaced, backupEntry{name: tarEntryName(fullPath, rel, rendered)})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("measure local content: %w", err)
+	}
+	if err := backupRemoteFiles(client, ctx, remotePath, opts.BackupName, replaced, logFn); err != nil {
+		return err
+	}
+
+	source := newTarSource(ctx, rendered, opts, logFn)
+	err = extractTarOnDevice(client, ctx, remotePath, opts, total, func(w io.Writer) error {
+		return source.streamPath(w, localPath, info)
+	}, logFn)
+	if err != nil {
+		return err
+	}
+	if err := verifyRemoteHashes(client, ctx, remotePath, source.hashes, logFn); err != nil {
+		return err
+	}
+	if err := applyOwnership(client, ctx, remotePath, source.written, opts); err != nil {
+		return err
+	}
+	if err := recordCopiedNames(client, ctx, remotePath, source.written); err != nil {
+		return err
+	}
+
+	logFn("Copy complete.", "")
+	return nil
+}
+
+// extractTarOnDevice runs tar on the device and feeds it the stream written by produce, compressed with
+// gzip when opts.Compress is set and the device supports it. Progress is measured on the uncompressed
+// stream against total, the expected tar size.
+func extractTarOnDevice(client *ssh.Client, ctx context.Context, remotePath string, opts CopyOptions, total int64, produce func(io.Writer) error, logFn func(string, string)) error {
+	compression := negotiateCompression(ctx, client, opts, logFn)
+	if compression != nil {
+		logFn("Transfer stream: "+compression.String(), "")
+	}
+
 	sess, err := client.NewSession()
 	if err != nil {
 		return fmt.Errorf("create session: %w", err)
@@ -50,7 +142,7 @@ func CopyPathToDevice(client *ssh.Client, ctx context.Context, localPath, remote
 	pipeReader, pipeWriter := io.Pipe()
 	sess.Stdin = pipeReader
 
-	cmd := fmt.Sprintf("tar -xpf - -C %s", shellQuote(remotePath))
+	cmd := fmt.Sprintf("tar %s - -C %s", compression.tarFlags(), shellQuote(remotePath))
 	if err := sess.Start(cmd); err != nil {
 		pipeReader.Close()
 		pipeWriter.Close()
@@ -59,7 +151,15 @@ func CopyPathToDevice(client *ssh.Client, ctx context.Context, localPath, remote
 
 	streamErrCh := make(chan error, 1)
 	go func() {
-		err := streamLocalPathToTar(ctx, pipeWriter, localPath, info, logFn)
+		err := writeCompressed(pipeWriter, compression, func(w io.Writer) error {
+			progress := newProgressWriter(w, "Transfer", total, logFn)
+			progress.onFraction = opts.Progress
+			if err := produce(progress); err != nil {
+				return err
+			}
+			progress.Finish()
+			return nil
+		})
 		if err != nil {
 			pipeWriter.CloseWithError(err)
 		} else {
@@ -113,41 +213,49 @@ func CopyPathToDevice(client *ssh.Client, ctx context.Context, localPath, remote
 		return fmt.Errorf("remote extraction: %w", sessionErr)
 	}
 
-	logFn("Copy complete.", "")
 	return nil
 }
 
-func streamLocalPathToTar(ctx context.Context, w io.Writer, basePath string, info os.FileInfo, logFn func(string, string)) error {
-	if err := checkCancellation(ctx); err != nil {
+// tarSource packages local files into a tar stream. Files found in rendered are sent with the rendered
+// content instead of their own; non-zero fileMode and dirMode replace the local permissions. The names
+// of all written entries are collected in written, the SHA-256 of every regular file sent in hashes.
+type tarSource struct {
+	ctx      context.Context
+	excludes []string
+	rendered map[string][]byte
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	logFn    func(string, string)
+	written  []string
+	hashes   map[string]string
+}
+
+func newTarSource(ctx context.Context, rendered map[string][]byte, opts CopyOptions, logFn func(string, string)) *tarSource {
+	return &tarSource{
+		ctx:      ctx,
+		excludes: opts.Excludes,
+		rendered: rendered,
+		fileMode: opts.FileMode,
+		dirMode:  opts.DirMode,
+		logFn:    logFn,
+		hashes:   make(map[string]string),
+	}
+}
+
+// streamPath writes basePath as tar stream to w.
+func (s *tarSource) streamPath(w io.Writer, basePath string, info os.FileInfo) error {
+	if err := checkCancellation(s.ctx); err != nil {
 		return err
 	}
 	tw := tar.NewWriter(w)
 	defer tw.Close()
 
-	if info.IsDir() {
-		return filepath.Walk(basePath, func(path string, fileInfo os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if err := checkCancellation(ctx); err != nil {
-				return err
-			}
-			rel, err := filepath.Rel(basePath, path)
-			if err != nil {
-				return err
-			}
-			rel = filepath.ToSlash(rel)
-			if rel == "." {
-				return nil
-			}
-			return writeTarEntry(tw, path, rel, fileInfo, logFn)
-		})
-	}
-
-	return writeTarEntry(tw, basePath, filepath.Base(basePath), info, logFn)
+	return walkCopySource(s.ctx, basePath, info, s.excludes, s.logFn, func(fullPath, rel string, fileInfo os.FileInfo) error {
+		return s.writeEntry(tw, fullPath, rel, fileInfo)
+	})
 }
 
-func writeTarEntry(tw *tar.Writer, fullPath, rel string, info os.FileInfo, logFn func(string, string)) error {
+func (s *tarSource) writeEntry(tw *tar.Writer, fullPath, rel string, info os.FileInfo) error {
 	mode := info.Mode()
 	linkTarget := ""
 	if mode&os.ModeSymlink != 0 {
@@ -162,35 +270,55 @@ func writeTarEntry(tw *tar.Writer, fullPath, rel string, info os.FileInfo, logFn
 	if err != nil {
 		return fmt.Errorf("tar header for '%s': %w", fullPath, err)
 	}
-	header.Name = strings.TrimPrefix(filepath.ToSlash(rel), "./")
+	header.Name = tarEntryName(fullPath, rel, s.rendered)
 	header.Format = tar.FormatPAX
 
 	if info.IsDir() && !strings.HasSuffix(header.Name, "/") {
 		header.Name += "/"
 	}
 
-	if mode.IsRegular() && shouldMarkExecutable(fullPath, info) {
+	content, isTemplate := s.rendered[fullPath]
+	if isTemplate {
+		header.Size = int64(len(content))
+	}
+
+	switch {
+	case info.IsDir() && s.dirMode != 0:
+		header.Mode = int64(s.dirMode.Perm())
+	case mode.IsRegular() && s.fileMode != 0:
+		header.Mode = int64(s.fileMode.Perm())
+	case mode.IsRegular() && shouldMarkExecutable(fullPath, info):
 		header.Mode = (header.Mode &^ 0o777) | 0o755
 	}
 
 	if err := tw.WriteHeader(header); err != nil {
 		return fmt.Errorf("write header for '%s': %w", fullPath, err)
 	}
+	s.written = append(s.written, strings.TrimSuffix(header.Name, "/"))
 
-	if mode.IsRegular() {
+	if isTemplate {
+		sum := sha256.Sum256(content)
+		s.hashes[header.Name] = hex.EncodeToString(sum[:])
+		if _, err := tw.Write(content); err != nil {
+			return fmt.Errorf("write rendered '%s': %w", fullPath, err)
+		}
+		s.logFn("Copied rendered template: "+header.Name, "")
+	} else if mode.IsRegular() {
 		file, err := os.Open(fullPath)
 		if err != nil {
 			return fmt.Errorf("open '%s': %w", fullPath, err)
 		}
 		defer file.Close()
-		if _, err := io.Copy(tw, file); err != nil {
+		hasher := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tw, hasher), file); err != nil {
 			return fmt.Errorf("copy '%s' contents: %w", fullPath, err)
 		}
-		logFn("Copied file: "+header.Name, "")
+		s.hashes[header.Name] = hex.EncodeToString(hasher.Sum(nil))
+		s.logFn("Copied file: "+header.Name, "")
 	} else if mode&os.ModeSymlink != 0 {
-		logFn("Copied symlink: "+header.Name, "")
+		s.logFn("Copied symlink: "+header.Name, "")
 	} else if info.IsDir() {
-		logFn("Created directory: "+header.Name, "")
+		s.logFn("Created directory: "+header.Name, "")
 	}
 
 	return nil
@@ -229,3 +357,64 @@ func shouldMarkExecutable(fullPath string, info os.FileInfo) bool {
 
 	return false
 }
+
+// tarEntryName returns the name of an entry on the device relative to the remote path; rendered
+// templates lose their extension.
+func tarEntryName(fullPath, rel string, rendered map[string][]byte) string {
+	name := strings.TrimPrefix(filepath.ToSlash(rel), "./")
+	if _, isTemplate := rendered[fullPath]; isTemplate {
+		name = strings.TrimSuffix(name, templateExtension)
+	}
+	return name
+}
+
+// tarEntrySize estimates the bytes an entry takes in the tar stream: a header block plus the content
+// padded to whole blocks. PAX extension headers for long names are not included.
+func tarEntrySize(fullPath string, info os.FileInfo, rendered map[string][]byte) int64 {
+	const blockSize = 512
+	size := int64(0)
+	if content, ok := rendered[fullPath]; ok {
+		size = int64(len(content))
+	} else if info.Mode().IsRegular() {
+		size = info.Size()
+	}
+	return blockSize + (size+blockSize-1)/blockSize*blockSize
+}
+
+// writeCompressed runs produce against w, wrapped by the compression if any.
+func writeCompressed(w io.Writer, compression *transferCompression, produce func(io.Writer) error) error {
+	target, closeFn, err := compression.wrap(w)
+	if err != nil {
+		return err
+	}
+	if err := produce(target); err != nil {
+		return err
+	}
+	return closeFn()
+}
+
+// applyOwnership changes owner and group of the copied entries. Names are passed in batches to stay below
+// the argument limit of the device shell.
+func applyOwnership(client *ssh.Client, ctx context.Context, remotePath string, names []string, opts CopyOptions) error {
+	if (opts.Owner == "" && opts.Group == "") || len(names) == 0 {
+		return nil
+	}
+
+	spec := opts.Owner
+	if opts.Group != "" {
+		spec += ":" + opts.Group
+	}
+
+	for start := 0; start < len(names); start += remoteBatchSize {
+		end := min(start+remoteBatchSize, len(names))
+		quoted := make([]string, 0, end-start)
+		for _, name := range names[start:end] {
+			quoted = append(quoted, shellQuote("./"+name))
+		}
+		cmd := fmt.Sprintf("cd %s && chown -h %s -- %s", shellQuote(remotePath), shellQuote(spec), strings.Join(quoted, " "))
+		if _, err := runSSHCommandContext(ctx, client, cmd, longSessionTimeout); err != nil {
+			return fmt.Errorf("set owner %s: %w", spec, err)
+		}
+	}
+	return nil
+}
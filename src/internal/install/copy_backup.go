@@ -0,0 +1,223 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// ConfigBackupDir holds the archives of remote files replaced by a config copy.
+	ConfigBackupDir = stateDir + "/backups"
+	// Number of installations whose backups are kept on the device.
+	configBackupKeep    = 5
+	configBackupTimeout = 5 * time.Minute
+	configBackupIDTime  = "20060102-150405"
+)
+
+// ConfigBackup is the backup written by one installation. It holds one archive per copy mapping, named
+// <ID>_<mapping number>. Each archive has a .tar with the replaced files, stored relative to / so that
+// it can be extracted in place, and an .added list of paths that did not exist before the copy.
+type ConfigBackup struct {
+	ID       string
+	Time     time.Time
+	Archives []string
+}
+
+// backupEntry is a path below the copy target that the copy will replace or delete.
+type backupEntry struct {
+	name string
+}
+
+// NewConfigBackupID returns the backup ID of an installation started at t.
+func NewConfigBackupID(t time.Time) string {
+	return t.Format(configBackupIDTime)
+}
+
+// configBackupArchive names the archive of the mapping with the given 0-based index.
+func configBackupArchive(id string, index int) string {
+	return fmt.Sprintf("%s_%d", id, index+1)
+}
+
+// backupRemoteFiles archives the entries that already exist below remotePath into the backup archive
+// name and records the ones that do not exist yet, so a restore can remove them again. Existing
+// directories are not archived, the copy only changes their content. Entries inside stateDir are
+// skipped so a backup never contains earlier backups. Nothing is written when the target is empty.
+// Devices whose tar cannot read the file list get no backup and only a warning.
+func backupRemoteFiles(client *ssh.Client, ctx context.Context, remotePath, name string, entries []backupEntry, logFn func(string, string)) error {
+	if name == "" || len(entries) == 0 {
+		return nil
+	}
+	supported, err := tarSupportsFileList(ctx, client)
+	if err != nil {
+		return fmt.Errorf("back up remote files: %w", err)
+	}
+	if !supported {
+		logFn(fmt.Sprintf("Warning: tar on the device does not support -T (BusyBox without FEATURE_TAR_FROM), %s is copied without a backup", remotePath), "")
+		return nil
+	}
+
+	var input strings.Builder
+	for _, entry := range entries {
+		target := path.Join(remotePath, entry.name)
+		if inStateDir(target) {
+			continue
+		}
+		fmt.Fprintf(&input, "%s\n", strings.TrimPrefix(target, "/"))
+	}
+
+	base := path.Join(ConfigBackupDir, name)
+	list, added, archive := base+".list", base+".added", base+".tar"
+	script := strings.Join([]string{
+		"set -e",
+		"mkdir -p " + shellQuote(ConfigBackupDir),
+		"cd /",
+		": > " + shellQuote(list),
+		": > " + shellQuote(added),
+		`while IFS= read -r p; do ` +
+			`if [ -L "$p" ] || [ -f "$p" ]; then printf '%s\n' "$p" >> ` + shellQuote(list) + `; ` +
+			`elif [ ! -d "$p" ]; then printf '/%s\n' "$p" >> ` + shellQuote(added) + `; fi; done`,
+		"if [ -s " + shellQuote(list) + " ]; then tar -cf " + shellQuote(archive) + " -T " + shellQuote(list) + "; fi",
+		"echo $(wc -l < " + shellQuote(list) + ") $(wc -l < " + shellQuote(added) + ")",
+		"rm -f " + shellQuote(list),
+		"if [ ! -s " + shellQuote(added) + " ]; then rm -f " + shellQuote(added) + "; fi",
+	}, "\n")
+
+	output, err := runSSHCommandInputContext(ctx, client, script, strings.NewReader(input.String()), configBackupTimeout)
+	if err != nil {
+		return fmt.Errorf("back up remote files: %w", err)
+	}
+
+	var saved, created int
+	if _, err := fmt.Sscanf(output, "%d %d", &saved, &created); err != nil {
+		return fmt.Errorf("back up remote files: unexpected output '%s'", output)
+	}
+	if saved == 0 {
+		logFn(fmt.Sprintf("No existing files in %s are replaced, nothing to back up.", remotePath), "")
+		return nil
+	}
+	logFn(fmt.Sprintf("Backed up %d existing entries of %s to %s", saved, remotePath, archive), "")
+	return nil
+}
+
+// tarSupportsFileList reports whether tar on the device reads the names to archive from a file with -T.
+// BusyBox only has the option when built with FEATURE_TAR_FROM.
+func tarSupportsFileList(ctx context.Context, client *ssh.Client) (bool, error) {
+	output, err := runSSHCommandContext(ctx, client, "if tar --help 2>&1 | grep -q -e '-T'; then echo yes; fi", shortSessionTimeout)
+	if err != nil {
+		return false, fmt.Errorf("check tar options: %w", err)
+	}
+	return output == "yes", nil
+}
+
+// ListConfigBackups returns the backups stored on the device, newest first.
+func ListConfigBackups(ctx context.Context, client *ssh.Client) ([]ConfigBackup, error) {
+	cmd := fmt.Sprintf("if [ -d %[1]s ]; then ls -1 %[1]s; fi", shellQuote(ConfigBackupDir))
+	output, err := runSSHCommandContext(ctx, client, cmd, shortSessionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("list config backups: %w", err)
+	}
+	return parseConfigBackups(output), nil
+}
+
+func parseConfigBackups(output string) []ConfigBackup {
+	byID := make(map[string]*ConfigBackup)
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(output, "\n") {
+		archive := strings.TrimSpace(line)
+		archive = strings.TrimSuffix(strings.TrimSuffix(archive, ".tar"), ".added")
+		id, number, found := strings.Cut(archive, "_")
+		if !found {
+			continue
+		}
+		created, err := time.ParseInLocation(configBackupIDTime, id, time.Local)
+		if err != nil {
+			continue
+		}
+		if _, err := strconv.Atoi(number); err != nil {
+			continue
+		}
+		if _, exists := seen[archive]; exists {
+			continue
+		}
+		seen[archive] = struct{}{}
+
+		backup, ok := byID[id]
+		if !ok {
+			backup = &ConfigBackup{ID: id, Time: created}
+			byID[id] = backup
+		}
+		backup.Archives = append(backup.Archives, archive)
+	}
+
+	backups := make([]ConfigBackup, 0, len(byID))
+	for _, backup := range byID {
+		sort.Slice(backup.Archives, func(i, j int) bool {
+			return archiveNumber(backup.Archives[i]) < archiveNumber(backup.Archives[j])
+		})
+		backups = append(backups, *backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ID > backups[j].ID })
+	return backups
+}
+
+func archiveNumber(archive string) int {
+	_, number, _ := strings.Cut(archive, "_")
+	n, _ := strconv.Atoi(number)
+	return n
+}
+
+// RestoreConfigBackup returns the device to the state before the installation that wrote backup.
+// Archives are restored in reverse mapping order: paths the copy created are removed and the replaced
+// files are extracted in place.
+func RestoreConfigBackup(ctx context.Context, client *ssh.Client, backup ConfigBackup, logFn func(string, string)) error {
+	if len(backup.Archives) == 0 {
+		return errors.New("backup contains no archives")
+	}
+
+	for i := len(backup.Archives) - 1; i >= 0; i-- {
+		if err := checkCancellation(ctx); err != nil {
+			return err
+		}
+		base := path.Join(ConfigBackupDir, backup.Archives[i])
+		added, archive := shellQuote(base+".added"), shellQuote(base+".tar")
+		cmd := "set -e; cd /; " +
+			"if [ -f " + added + " ]; then while IFS= read -r p; do if [ -n \"$p\" ] && [ \"$p\" != / ]; then rm -rf -- \"$p\"; fi; done < " + added + "; fi; " +
+			"if [ -f " + archive + " ]; then tar -xpf " + archive + " -C /; fi"
+		logFn("Restoring "+backup.Archives[i], "")
+		if _, err := runSSHCommandContext(ctx, client, cmd, configBackupTimeout); err != nil {
+			return fmt.Errorf("restore %s: %w", backup.Archives[i], err)
+		}
+	}
+
+	logFn(fmt.Sprintf("Restored the configuration from %s.", backup.Time.Format("2006-01-02 15:04:05")), "")
+	return nil
+}
+
+// pruneConfigBackups removes all but the newest configBackupKeep backups from the device.
+func pruneConfigBackups(ctx context.Context, client *ssh.Client, logFn func(string, string)) error {
+	backups, err := ListConfigBackups(ctx, client)
+	if err != nil {
+		return err
+	}
+	if len(backups) <= configBackupKeep {
+		return nil
+	}
+
+	var targets []string
+	for _, backup := range backups[configBackupKeep:] {
+		targets = append(targets, shellQuote(path.Join(ConfigBackupDir, backup.ID))+"_*")
+	}
+	if _, err := runSSHCommandContext(ctx, client, "rm -f -- "+strings.Join(targets, " "), shortSessionTimeout); err != nil {
+		return fmt.Errorf("remove old config backups: %w", err)
+	}
+	logFn(fmt.Sprintf("Removed %d old config backup(s) from the device.", len(backups)-configBackupKeep), "")
+	return nil
+}
@@ -0,0 +1,69 @@
+package install
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// gzipSupportCommand prints "gzip" when the device tar can decompress gzip streams with -z.
+const gzipSupportCommand = "if tar --help 2>&1 | grep -qi gzip && command -v gzip >/dev/null 2>&1; then echo gzip; fi"
+
+// transferCompression describes how the tar stream is compressed. A nil value sends it uncompressed.
+type transferCompression struct {
+	level int
+}
+
+// negotiateCompression checks whether the device tar supports gzip. Without support, or when the check
+// fails, the transfer silently falls back to an uncompressed stream.
+func negotiateCompression(ctx context.Context, client *ssh.Client, opts CopyOptions, logFn func(string, string)) *transferCompression {
+	if !opts.Compress {
+		return nil
+	}
+
+	output, err := runSSHCommandContext(ctx, client, gzipSupportCommand, shortSessionTimeout)
+	if err != nil || strings.TrimSpace(output) != "gzip" {
+		logFn("Device tar has no gzip support, sending uncompressed", "")
+		return nil
+	}
+
+	level := opts.CompressionLevel
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	return &transferCompression{level: level}
+}
+
+// tarFlags returns the tar extraction flags matching the compression.
+func (c *transferCompression) tarFlags() string {
+	if c == nil {
+		return "-xpf"
+	}
+	return "-xzpf"
+}
+
+// wrap returns a writer compressing into w and a function that flushes and closes it.
+func (c *transferCompression) wrap(w io.Writer) (io.Writer, func() error, error) {
+	if c == nil {
+		return w, func() error { return nil }, nil
+	}
+	gz, err := gzip.NewWriterLevel(w, c.level)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create gzip writer: %w", err)
+	}
+	return gz, gz.Close, nil
+}
+
+func (c *transferCompression) String() string {
+	if c == nil {
+		return "uncompressed"
+	}
+	if c.level == gzip.DefaultCompression {
+		return "gzip"
+	}
+	return fmt.Sprintf("gzip level %d", c.level)
+}
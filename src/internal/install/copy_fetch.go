@@ -0,0 +1,340 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const copyFromDeviceTimeout = 20 * time.Minute
+
+// fetchTarget receives the entries fetched from the device, either into a local folder or a .tar.gz file.
+type fetchTarget interface {
+	add(header *tar.Header, r io.Reader) error
+	Close() error
+}
+
+// isArchivePath reports whether localPath names a .tar.gz archive rather than a folder.
+func isArchivePath(localPath string) bool {
+	lower := strings.ToLower(localPath)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+func openFetchTarget(localPath string, logFn func(string, string)) (fetchTarget, error) {
+	if isArchivePath(localPath) {
+		if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+			return nil, fmt.Errorf("create archive folder: %w", err)
+		}
+		file, err := os.Create(localPath)
+		if err != nil {
+			return nil, fmt.Errorf("create archive: %w", err)
+		}
+		gz := gzip.NewWriter(file)
+		return &archiveTarget{file: file, gz: gz, tw: tar.NewWriter(gz)}, nil
+	}
+
+	return newFolderTarget(localPath, logFn)
+}
+
+// archiveTarget writes the fetched entries into a gzip-compressed tar file.
+type archiveTarget struct {
+	file *os.File
+	gz   *gzip.Writer
+	tw   *tar.Writer
+}
+
+func (t *archiveTarget) add(header *tar.Header, r io.Reader) error {
+	if err := t.tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write header for '%s': %w", header.Name, err)
+	}
+	if header.Typeflag == tar.TypeReg {
+		if _, err := io.Copy(t.tw, r); err != nil {
+			return fmt.Errorf("write '%s': %w", header.Name, err)
+		}
+	}
+	return nil
+}
+
+func (t *archiveTarget) Close() error {
+	return errors.Join(t.tw.Close(), t.gz.Close(), t.file.Close())
+}
+
+// folderTarget extracts the fetched entries below a folder. All access goes through an os.Root, so
+// neither ../ names nor symlinks from earlier entries can write outside the folder. Such entries, and
+// entries that cannot be reproduced locally, are skipped with a log line or, when strict is set, fail.
+type folderTarget struct {
+	root   *os.Root
+	strict bool
+	logFn  func(string, string)
+}
+
+func newFolderTarget(localPath string, logFn func(string, string)) (*folderTarget, error) {
+	if err := os.MkdirAll(localPath, 0o755); err != nil {
+		return nil, fmt.Errorf("create local folder: %w", err)
+	}
+	root, err := os.OpenRoot(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("open local folder: %w", err)
+	}
+	return &folderTarget{root: root, logFn: logFn}, nil
+}
+
+func (t *folderTarget) add(header *tar.Header, r io.Reader) error {
+	name := filepath.FromSlash(path.Clean(strings.TrimPrefix(header.Name, "./")))
+	if !filepath.IsLocal(name) {
+		return t.skip("entry outside the target folder: " + header.Name)
+	}
+	mode := os.FileMode(header.Mode).Perm()
+
+	err := t.write(header, name, mode, r)
+	if err != nil && isEscapeError(err) {
+		return t.skip("entry that leads outside the target folder through a link: " + header.Name)
+	}
+	return err
+}
+
+// skip logs that an entry was left out, or fails in strict mode, where a partial copy is not acceptable.
+func (t *folderTarget) skip(what string) error {
+	if t.strict {
+		return errors.New("cannot extract " + what)
+	}
+	t.logFn("Skipped "+what, "")
+	return nil
+}
+
+func (t *folderTarget) write(header *tar.Header, name string, mode os.FileMode, r io.Reader) error {
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return t.root.MkdirAll(name, mode|0o700)
+	case tar.TypeReg:
+		if err := t.root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		file, err := t.root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0o600)
+		if err != nil {
+			return fmt.Errorf("create '%s': %w", name, err)
+		}
+		if _, err := io.Copy(file, r); err != nil {
+			file.Close()
+			return fmt.Errorf("write '%s': %w", name, err)
+		}
+		return file.Close()
+	case tar.TypeSymlink:
+		if err := t.root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		_ = t.root.Remove(name)
+		if err := t.root.Symlink(header.Linkname, name); err != nil {
+			if isEscapeError(err) {
+				return err
+			}
+			// Creating symlinks needs extra privileges on Windows.
+			return t.skip(fmt.Sprintf("symlink %s -> %s: %v", header.Name, header.Linkname, err))
+		}
+		return nil
+	case tar.TypeLink:
+		target := filepath.FromSlash(path.Clean(strings.TrimPrefix(header.Linkname, "./")))
+		if !filepath.IsLocal(target) {
+			return t.skip(fmt.Sprintf("hard link %s -> %s outside the target folder", header.Name, header.Linkname))
+		}
+		if err := t.root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		_ = t.root.Remove(name)
+		if err := t.root.Link(target, name); err != nil {
+			if isEscapeError(err) {
+				return err
+			}
+			return t.skip(fmt.Sprintf("hard link %s -> %s: %v", header.Name, header.Linkname, err))
+		}
+		return nil
+	default:
+		return t.skip(fmt.Sprintf("%s of unsupported type %q", header.Name, header.Typeflag))
+	}
+}
+
+func (t *folderTarget) Close() error {
+	return t.root.Close()
+}
+
+// isEscapeError reports whether err is os.Root refusing a path that resolves outside the root.
+func isEscapeError(err error) bool {
+	var pathErr *os.PathError
+	return errors.As(err, &pathErr) && pathErr.Err != nil && strings.Contains(pathErr.Err.Error(), "escapes from parent")
+}
+
+// CopyPathFromDevice is the reverse of CopyPathToDevice: it fetches remotePath, a file or directory, from
+// the device. A localPath ending in .tar.gz or .tgz receives the content as archive; any other localPath
+// is a folder the content is extracted into. In both cases the entries keep the base name of remotePath,
+// so fetching /var/log into out yields out/log.
+func CopyPathFromDevice(client *ssh.Client, ctx context.Context, remotePath, localPath string, opts CopyOptions, logFn func(string, string)) (err error) {
+	ctx = contextOrBackground(ctx)
+	if err := checkCancellation(ctx); err != nil {
+		return err
+	}
+	localPath = filepath.Clean(strings.TrimSpace(localPath))
+	if localPath == "." {
+		return errors.New("local path must not be empty")
+	}
+
+	target, err := openFetchTarget(localPath, logFn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, target.Close())
+	}()
+
+	logFn(fmt.Sprintf("Fetching %s to %s", remotePath, localPath), "")
+	if err := fetchRemotePath(client, ctx, remotePath, "", target, opts, logFn); err != nil {
+		return err
+	}
+	logFn("Fetch complete.", "")
+	return nil
+}
+
+// fetchRemotePath streams remotePath as tar from the device, compressed with gzip when opts.Compress is set
+// and the device supports it, and hands every entry to target with prefix prepended to its name. Progress
+// counts the uncompressed tar stream against the size reported by du. When tar fails after some entries
+// were sent, for example because a file vanished while it was read, the failure is logged and the
+// fetched entries are kept.
+func fetchRemotePath(client *ssh.Client, ctx context.Context, remotePath, prefix string, target fetchTarget, opts CopyOptions, logFn func(string, string)) error {
+	remotePath = path.Clean(strings.TrimSpace(remotePath))
+	if !path.IsAbs(remotePath) || remotePath == "/" {
+		return fmt.Errorf("remote path '%s' must be an absolute path below /", remotePath)
+	}
+	parent, base := path.Split(remotePath)
+
+	var total int64
+	if output, err := runSSHCommandContext(ctx, client, "du -sk "+shellQuote(remotePath), shortSessionTimeout); err == nil {
+		if fields := strings.Fields(output); len(fields) > 0 {
+			kib, _ := strconv.ParseInt(fields[0], 10, 64)
+			total = kib * 1024
+		}
+	}
+
+	compression := negotiateCompression(ctx, client, opts, logFn)
+	createFlags := "-cf"
+	if compression != nil {
+		createFlags = "-czf"
+	}
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+	defer sess.Close()
+
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("stdout pipe: %w", err)
+	}
+	var stderr bytes.Buffer
+	sess.Stderr = &stderr
+
+	cmd := fmt.Sprintf("tar %s - -C %s %s", createFlags, shellQuote(parent), shellQuote(base))
+	if err := sess.Start(cmd); err != nil {
+		return fmt.Errorf("start remote archive: %w", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		timer := time.NewTimer(copyFromDeviceTimeout)
+		defer timer.Stop()
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			logFn("Fetch timed out; aborting remote archive", "")
+		case <-ctx.Done():
+		}
+		_ = sess.Signal(ssh.SIGKILL)
+		_ = sess.Close()
+	}()
+
+	var (
+		entries int
+		readErr error
+	)
+	stream, err := decompressFetchStream(stdout, compression != nil)
+	switch {
+	case err != nil:
+		readErr = err
+	case stream != nil:
+		progress := newProgressReader(stream, "Download", total, logFn)
+		progress.onFraction = opts.Progress
+		entries, readErr = readFetchStream(progress, prefix, target)
+		if readErr == nil {
+			progress.Finish()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return fmt.Errorf("read remote archive: %w", readErr)
+	}
+
+	if err := sess.Wait(); err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if entries == 0 {
+			return fmt.Errorf("remote archive of %s: %w (stderr: %s)", remotePath, err, detail)
+		}
+		logFn(fmt.Sprintf("Warning: tar reported errors while reading %s: %s", remotePath, detail), "")
+	}
+	return nil
+}
+
+// decompressFetchStream returns the uncompressed tar stream of r. It returns a nil reader when a compressed
+// stream is empty because tar failed before writing anything; the caller reports its exit status.
+func decompressFetchStream(r io.Reader, compressed bool) (io.Reader, error) {
+	if !compressed {
+		return r, nil
+	}
+	gz, err := gzip.NewReader(r)
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	return gz, err
+}
+
+func readFetchStream(r io.Reader, prefix string, target fetchTarget) (int, error) {
+	tr := tar.NewReader(r)
+	entries := 0
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		if prefix != "" {
+			header.Name = path.Join(prefix, header.Name)
+			if header.Typeflag == tar.TypeDir {
+				header.Name += "/"
+			}
+			// Hard links name another entry of the stream, which moved below prefix as well.
+			if header.Typeflag == tar.TypeLink {
+				header.Linkname = path.Join(prefix, header.Linkname)
+			}
+		}
+		if err := target.add(header, tr); err != nil {
+			return entries, err
+		}
+		entries++
+	}
+}
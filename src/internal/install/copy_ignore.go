@@ -0,0 +1,214 @@
+package install
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"wago-init/internal/fs"
+)
+
+// ignoreFileName is read from every copied directory. Its patterns follow .gitignore semantics and apply
+// to the directory it is found in.
+const ignoreFileName = ".wagoignore"
+
+type ignoreRule struct {
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreMatcher decides which entries of a copy source are excluded. Rules are evaluated in order and the
+// last matching rule wins, so later rules (deeper ignore files) can re-include with '!'.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// ParseExcludePatterns converts the stored multiline exclude patterns into one pattern per line.
+func ParseExcludePatterns(raw string) []string {
+	decoded := strings.ReplaceAll(fs.DecodeMultilineValue(raw), "\r\n", "\n")
+
+	var patterns []string
+	for _, line := range strings.Split(decoded, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			patterns = append(patterns, trimmed)
+		}
+	}
+	return patterns
+}
+
+func newIgnoreMatcher(patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	for _, pattern := range patterns {
+		m.addPattern("", pattern)
+	}
+	return m
+}
+
+// loadFile adds the patterns of the ignore file in dir, whose path relative to the copy root is base.
+// A missing file is not an error.
+func (m *ignoreMatcher) loadFile(dir, base string) error {
+	file, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		m.addPattern(base, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func (m *ignoreMatcher) addPattern(base, line string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return
+	}
+	rule.segments = strings.Split(line, "/")
+	m.rules = append(m.rules, rule)
+}
+
+// excluded reports whether the entry at rel (slash separated, relative to the copy root) is excluded.
+func (m *ignoreMatcher) excluded(rel string, isDir bool) bool {
+	excluded := false
+	for _, rule := range m.rules {
+		if rule.matches(rel, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// excludedWithParents reports whether rel or one of its parent directories is excluded.
+func (m *ignoreMatcher) excludedWithParents(rel string, isDir bool) bool {
+	if m.excluded(rel, isDir) {
+		return true
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if m.excluded(dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**" matches any number of segments.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// walkCopySource calls fn for every entry of the copy source that is not excluded by the exclude patterns
+// or a .wagoignore file. Excluded entries are reported through logFn; excluded directories are skipped
+// as a whole. A single file is always passed to fn.
+func walkCopySource(ctx context.Context, basePath string, info os.FileInfo, excludes []string, logFn func(string, string), fn func(fullPath, rel string, info os.FileInfo) error) error {
+	if err := checkCancellation(ctx); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fn(basePath, filepath.Base(basePath), info)
+	}
+
+	matcher := newIgnoreMatcher(excludes)
+	if err := matcher.loadFile(basePath, ""); err != nil {
+		return err
+	}
+
+	return filepath.Walk(basePath, func(fullPath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := checkCancellation(ctx); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(basePath, fullPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if fileInfo.Name() == ignoreFileName && !fileInfo.IsDir() {
+			return nil
+		}
+
+		if matcher.excluded(rel, fileInfo.IsDir()) {
+			if fileInfo.IsDir() {
+				logFn("Excluded: "+rel+"/", "")
+				return filepath.SkipDir
+			}
+			logFn("Excluded: "+rel, "")
+			return nil
+		}
+
+		if fileInfo.IsDir() {
+			if err := matcher.loadFile(fullPath, rel); err != nil {
+				return err
+			}
+		}
+		return fn(fullPath, rel, fileInfo)
+	})
+}
@@ -0,0 +1,90 @@
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// stateDir holds what wago-init keeps on the device. It lies outside every sensible copy target.
+	stateDir = "/var/lib/wago-init"
+	// copyManifestDir holds one manifest per copy target listing the names wago-init deployed there.
+	copyManifestDir = stateDir + "/manifests"
+)
+
+// inStateDir reports whether the absolute device path p lies inside stateDir. Copies never back up,
+// overwrite records of or delete anything there.
+func inStateDir(p string) bool {
+	p = path.Clean(p)
+	return p == stateDir || strings.HasPrefix(p, stateDir+"/")
+}
+
+// copyManifestPath returns the manifest of remotePath. The file name is derived from a hash, so every
+// target gets its own manifest regardless of the characters in its path.
+func copyManifestPath(remotePath string) string {
+	sum := sha256.Sum256([]byte(path.Clean(remotePath)))
+	return path.Join(copyManifestDir, hex.EncodeToString(sum[:8])+".list")
+}
+
+// readCopyManifest returns the names a previous copy deployed below remotePath. A missing manifest
+// returns an empty set.
+func readCopyManifest(client *ssh.Client, ctx context.Context, remotePath string) (map[string]struct{}, error) {
+	manifest := shellQuote(copyManifestPath(remotePath))
+	output, err := runSSHCommandContext(ctx, client, fmt.Sprintf("if [ -f %[1]s ]; then cat %[1]s; fi", manifest), shortSessionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("read copy manifest: %w", err)
+	}
+
+	names := make(map[string]struct{})
+	for _, line := range strings.Split(output, "\n") {
+		if name := strings.TrimRight(line, "\r"); name != "" {
+			names[name] = struct{}{}
+		}
+	}
+	return names, nil
+}
+
+// recordCopiedNames adds the names a full copy deployed to the manifest of remotePath.
+func recordCopiedNames(client *ssh.Client, ctx context.Context, remotePath string, names []string) error {
+	previous, err := readCopyManifest(client, ctx, remotePath)
+	if err != nil {
+		return err
+	}
+	return writeCopyManifest(client, ctx, remotePath, names, previous, nil)
+}
+
+// writeCopyManifest records the names deployed below remotePath: the current ones plus those of earlier
+// copies that are still on the device, so a later sync can remove them once they vanish locally.
+func writeCopyManifest(client *ssh.Client, ctx context.Context, remotePath string, current []string, previous map[string]struct{}, deleted []string) error {
+	names := make(map[string]struct{}, len(current)+len(previous))
+	for name := range previous {
+		names[name] = struct{}{}
+	}
+	for _, name := range deleted {
+		delete(names, name)
+	}
+	for _, name := range current {
+		names[name] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	manifest := copyManifestPath(remotePath)
+	cmd := fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(copyManifestDir), shellQuote(manifest))
+	input := strings.Join(sorted, "\n") + "\n"
+	if _, err := runSSHCommandInputContext(ctx, client, cmd, strings.NewReader(input), shortSessionTimeout); err != nil {
+		return fmt.Errorf("write copy manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"wago-init/internal/fs"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const postCopyCommandTimeout = 5 * time.Minute
+
+var ownerPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$|^[0-9]+$`)
+
+// CopyMapping copies one local file or directory to RemotePath on the device. LocalPath may also be a
+// .zip or .tar.gz archive, or a git repository copied at GitRef (see prepareCopySource). Owner, Group and
+// the modes are optional; PostCommand runs on the device after a successful copy, e.g. to restart a service.
+type CopyMapping struct {
+	LocalPath   string
+	GitRef      string
+	RemotePath  string
+	Owner       string
+	Group       string
+	FileMode    os.FileMode
+	DirMode     os.FileMode
+	PostCommand string
+}
+
+// CopyMappingsFromConfig converts and validates the stored copy mappings.
+func CopyMappingsFromConfig(configs []fs.CopyMapping) ([]CopyMapping, error) {
+	mappings := make([]CopyMapping, 0, len(configs))
+	for i, cfg := range configs {
+		mapping, err := copyMappingFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("copy mapping %d: %w", i+1, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+func copyMappingFromConfig(cfg fs.CopyMapping) (CopyMapping, error) {
+	mapping := CopyMapping{
+		LocalPath:   strings.TrimSpace(cfg.Local),
+		GitRef:      strings.TrimSpace(cfg.GitRef),
+		RemotePath:  strings.TrimSpace(cfg.Remote),
+		Owner:       strings.TrimSpace(cfg.Owner),
+		Group:       strings.TrimSpace(cfg.Group),
+		PostCommand: strings.TrimSpace(fs.DecodeMultilineValue(cfg.PostCommand)),
+	}
+
+	if mapping.LocalPath == "" {
+		return mapping, errors.New("local path is empty")
+	}
+	if strings.HasPrefix(mapping.GitRef, "-") || strings.ContainsAny(mapping.GitRef, " \t") {
+		return mapping, fmt.Errorf("invalid git ref '%s'", mapping.GitRef)
+	}
+	if mapping.GitRef != "" && isSourceArchive(mapping.LocalPath) {
+		return mapping, errors.New("a git ref can only be used with a repository folder, not an archive")
+	}
+	if !strings.HasPrefix(mapping.RemotePath, "/") {
+		return mapping, fmt.Errorf("remote path '%s' must be absolute", mapping.RemotePath)
+	}
+	if mapping.Owner != "" && !ownerPattern.MatchString(mapping.Owner) {
+		return mapping, fmt.Errorf("invalid owner '%s'", mapping.Owner)
+	}
+	if mapping.Group != "" && !ownerPattern.MatchString(mapping.Group) {
+		return mapping, fmt.Errorf("invalid group '%s'", mapping.Group)
+	}
+
+	var err error
+	if mapping.FileMode, err = parseFileMode(cfg.FileMode); err != nil {
+		return mapping, fmt.Errorf("file mode: %w", err)
+	}
+	if mapping.DirMode, err = parseFileMode(cfg.DirMode); err != nil {
+		return mapping, fmt.Errorf("directory mode: %w", err)
+	}
+	return mapping, nil
+}
+
+// parseFileMode parses an octal permission such as 644 or 0755. An empty value returns zero.
+func parseFileMode(raw string) (os.FileMode, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil || value > 0o777 {
+		return 0, fmt.Errorf("invalid mode '%s' (expected octal permissions such as 644)", raw)
+	}
+	return os.FileMode(value), nil
+}
+
+// copyMappingsToDevice copies every mapping with the shared options and runs its post-copy command.
+// With params.CopyBackup the replaced remote files are backed up first, one archive per mapping.
+func copyMappingsToDevice(client *ssh.Client, params *Parameters, base CopyOptions, logFn func(string, string)) error {
+	if len(params.CopyMappings) == 0 {
+		logFn("No copy mappings configured, skipping config copy.", "")
+		return nil
+	}
+
+	backupID := ""
+	if params.CopyBackup && !base.DryRun {
+		backupID = NewConfigBackupID(time.Now())
+	}
+
+	for i, mapping := range params.CopyMappings {
+		if err := checkCancellation(params.Context); err != nil {
+			return err
+		}
+
+		opts := base
+		if base.Progress != nil {
+			index, count := float64(i), float64(len(params.CopyMappings))
+			opts.Progress = func(fraction float64) {
+				base.Progress((index + fraction) / count)
+			}
+		}
+		opts.Owner = mapping.Owner
+		opts.Group = mapping.Group
+		opts.FileMode = mapping.FileMode
+		opts.DirMode = mapping.DirMode
+		if backupID != "" {
+			opts.BackupName = configBackupArchive(backupID, i)
+		}
+
+		source, cleanup, err := prepareCopySource(params.Context, mapping.LocalPath, mapping.GitRef, logFn)
+		if err != nil {
+			return fmt.Errorf("prepare %s: %w", mapping.LocalPath, err)
+		}
+		err = withTransferSlot(params, "config copy", logFn, func() error {
+			return CopyPathToDevice(client, params.Context, source, mapping.RemotePath, opts, logFn)
+		})
+		cleanup()
+		if err != nil {
+			return fmt.Errorf("copy %s to %s: %w", mapping.LocalPath, mapping.RemotePath, err)
+		}
+
+		if mapping.PostCommand == "" {
+			continue
+		}
+		if opts.DryRun {
+			logFn("Dry run: skipping post-copy command: "+mapping.PostCommand, "")
+			continue
+		}
+		logFn("Running post-copy command: "+mapping.PostCommand, "")
+		if err := runSSHCommandStreamingContext(params.Context, client, mapping.PostCommand, postCopyCommandTimeout, logFn); err != nil {
+			return fmt.Errorf("post-copy command for %s: %w", mapping.RemotePath, err)
+		}
+	}
+
+	if backupID != "" {
+		return pruneConfigBackups(params.Context, client, logFn)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// isSourceArchive reports whether localPath is an archive that is unpacked before copying.
+func isSourceArchive(localPath string) bool {
+	return isArchivePath(localPath) || strings.HasSuffix(strings.ToLower(localPath), ".zip")
+}
+
+// prepareCopySource resolves the local side of a copy mapping. Files and folders are used as they are.
+// .zip, .tar.gz and .tgz archives and git repositories with a gitRef are unpacked straight from the
+// archive or from git archive into a staging folder, which the returned cleanup removes. Entries the
+// staging folder cannot reproduce, such as symlinks on Windows without the needed privilege, fail the copy
+// instead of being dropped. The archive hash or commit SHA is logged, so the session output tells which
+// config revision went onto the device.
+func prepareCopySource(ctx context.Context, localPath, gitRef string, logFn func(string, string)) (string, func(), error) {
+	noCleanup := func() {}
+	if gitRef == "" && !isSourceArchive(localPath) {
+		return localPath, noCleanup, nil
+	}
+
+	staging, err := os.MkdirTemp("", "wago-init-copy-")
+	if err != nil {
+		return "", noCleanup, fmt.Errorf("create staging folder: %w", err)
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(staging)
+	}
+	target, err := newFolderTarget(staging, logFn)
+	if err != nil {
+		cleanup()
+		return "", noCleanup, err
+	}
+	target.strict = true
+
+	switch {
+	case gitRef != "":
+		err = unpackGitRevision(ctx, localPath, gitRef, target, logFn)
+	case strings.HasSuffix(strings.ToLower(localPath), ".zip"):
+		err = unpackZip(ctx, localPath, target, logFn)
+	default:
+		err = unpackTarGz(ctx, localPath, target, logFn)
+	}
+	err = errors.Join(err, target.Close())
+	if err != nil {
+		cleanup()
+		return "", noCleanup, err
+	}
+	return staging, cleanup, nil
+}
+
+func unpackTarGz(ctx context.Context, archivePath string, target fetchTarget, logFn func(string, string)) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(file, hash))
+	if err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+	defer gz.Close()
+
+	if err := unpackTar(ctx, gz, target); err != nil {
+		return fmt.Errorf("unpack archive: %w", err)
+	}
+	// Hash trailing bytes gzip did not need, so the sum covers the whole file.
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("hash archive: %w", err)
+	}
+
+	logFn(fmt.Sprintf("Config source: archive %s (sha256 %s)", archivePath, hex.EncodeToString(hash.Sum(nil))), "")
+	return nil
+}
+
+func unpackZip(ctx context.Context, archivePath string, target fetchTarget, logFn func(string, string)) error {
+	sum, err := hashLocalFile(archivePath)
+	if err != nil {
+		return fmt.Errorf("hash archive: %w", err)
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		if err := checkCancellation(ctx); err != nil {
+			return err
+		}
+		if err := unpackZipEntry(entry, target); err != nil {
+			return fmt.Errorf("unpack '%s': %w", entry.Name, err)
+		}
+	}
+
+	logFn(fmt.Sprintf("Config source: archive %s (sha256 %s)", archivePath, sum), "")
+	return nil
+}
+
+// unpackZipEntry hands one zip entry to target as the equivalent tar entry. Zip stores the target of a
+// symlink as its content.
+func unpackZipEntry(entry *zip.File, target fetchTarget) error {
+	info := entry.FileInfo()
+	header := &tar.Header{
+		Name:    entry.Name,
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: entry.Modified,
+	}
+
+	content, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	switch {
+	case info.IsDir():
+		header.Typeflag = tar.TypeDir
+		return target.add(header, content)
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = string(link)
+		return target.add(header, content)
+	case info.Mode().IsRegular():
+		header.Typeflag = tar.TypeReg
+		header.Size = int64(entry.UncompressedSize64)
+		return target.add(header, content)
+	}
+	return fmt.Errorf("unsupported entry type %s", info.Mode().Type())
+}
+
+// unpackGitRevision resolves gitRef in the repository at repoPath and unpacks that revision from the
+// output of git archive. Uncommitted changes and ignored files are therefore never copied.
+func unpackGitRevision(ctx context.Context, repoPath, gitRef string, target fetchTarget, logFn func(string, string)) error {
+	ctx = contextOrBackground(ctx)
+
+	revParse := exec.CommandContext(ctx, "git", "-C", repoPath, "rev-parse", "--verify", "--end-of-options", gitRef+"^{commit}")
+	hideConsoleWindow(revParse)
+	var stderr bytes.Buffer
+	revParse.Stderr = &stderr
+	out, err := revParse.Output()
+	if err != nil {
+		return fmt.Errorf("resolve git ref '%s' in %s: %w (%s)", gitRef, repoPath, err, strings.TrimSpace(stderr.String()))
+	}
+	commit := strings.TrimSpace(string(out))
+
+	archive := exec.CommandContext(ctx, "git", "-C", repoPath, "archive", "--format=tar", commit)
+	hideConsoleWindow(archive)
+	stderr.Reset()
+	archive.Stderr = &stderr
+	stdout, err := archive.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("git archive: %w", err)
+	}
+	if err := archive.Start(); err != nil {
+		return fmt.Errorf("start git archive: %w", err)
+	}
+
+	unpackErr := unpackTar(ctx, stdout, target)
+	if unpackErr != nil {
+		// Unblock git if it is still writing.
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	waitErr := archive.Wait()
+	if unpackErr != nil {
+		return fmt.Errorf("unpack git archive: %w", unpackErr)
+	}
+	if waitErr != nil {
+		return fmt.Errorf("git archive %s: %w (%s)", commit, waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	logFn(fmt.Sprintf("Config source: git repository %s at %s (commit %s)", repoPath, gitRef, commit), "")
+	return nil
+}
+
+func unpackTar(ctx context.Context, r io.Reader, target fetchTarget) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := checkCancellation(ctx); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// git archive stores the commit ID in a global PAX header.
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		if err := target.add(header, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package install
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Hashing a large tree on the device takes a while.
+const syncListTimeout = 5 * time.Minute
+
+// syncEntry is a local file system entry considered for transfer.
+type syncEntry struct {
+	fullPath string
+	rel      string // name inside the tar stream, before template renaming
+	name     string // name on the device relative to the remote path
+	info     os.FileInfo
+	hash     string
+}
+
+// remoteEntry is what the device reported for one path: kind 'd', 'f' or 'l' and the hash for files.
+type remoteEntry struct {
+	kind byte
+	hash string
+}
+
+// syncPathToDevice compares localPath with remotePath by SHA-256 and transfers only new and changed
+// entries. Symlinks are always transferred. With opts.SyncDelete entries that an earlier copy deployed
+// (see copy_manifest.go) and that are missing locally now are removed; anything else on the device is
+// never deleted. With opts.DryRun the plan is only logged.
+func syncPathToDevice(client *ssh.Client, ctx context.Context, localPath, remotePath string, info os.FileInfo, rendered map[string][]byte, opts CopyOptions, logFn func(string, string)) error {
+	if opts.SyncDelete && path.Clean(remotePath) == "/" {
+		return errors.New("refusing to delete extraneous files below /")
+	}
+	logFn(fmt.Sprintf("Comparing %s with %s on the device", localPath, remotePath), "")
+
+	local, err := collectSyncEntries(ctx, localPath, info, rendered, opts.Excludes, logFn)
+	if err != nil {
+		return fmt.Errorf("scan local content: %w", err)
+	}
+
+	listCmd := remoteListCommand(remotePath, info.IsDir(), filepath.Base(localPath))
+	output, err := runSSHCommandContext(ctx, client, listCmd, syncListTimeout)
+	if err != nil {
+		return fmt.Errorf("list remote files: %w", err)
+	}
+	remote := parseRemoteListing(output)
+
+	deployed, err := readCopyManifest(client, ctx, remotePath)
+	if err != nil {
+		return err
+	}
+
+	var transfer []syncEntry
+	var added, changed, unchanged []string
+	localNames := make(map[string]struct{}, len(local))
+	for _, entry := range local {
+		localNames[entry.name] = struct{}{}
+		existing, exists := remote[entry.name]
+		mode := entry.info.Mode()
+		switch {
+		case entry.info.IsDir():
+			if exists && existing.kind == 'd' {
+				continue
+			}
+			added = append(added, entry.name+"/")
+		case mode&os.ModeSymlink != 0:
+			if exists {
+				changed = append(changed, entry.name)
+			} else {
+				added = append(added, entry.name)
+			}
+		case !mode.IsRegular():
+			continue
+		case !exists:
+			added = append(added, entry.name)
+		case existing.kind != 'f' || existing.hash != entry.hash:
+			changed = append(changed, entry.name)
+		default:
+			unchanged = append(unchanged, entry.name)
+			continue
+		}
+		transfer = append(transfer, entry)
+	}
+
+	var removed []string
+	if opts.SyncDelete && info.IsDir() {
+		// Excluded paths are neither copied nor deleted, like rsync does by default.
+		protected := newIgnoreMatcher(opts.Excludes)
+		if err := protected.loadFile(localPath, ""); err != nil {
+			return err
+		}
+		for _, entry := range local {
+			if entry.info.IsDir() {
+				if err := protected.loadFile(entry.fullPath, entry.name); err != nil {
+					return err
+				}
+			}
+		}
+		removed = extraneousRemoteEntries(remotePath, remote, localNames, deployed, protected)
+		if len(deployed) == 0 {
+			logFn("No earlier copy is recorded for this target, so no files are deleted.", "")
+		}
+	}
+
+	logSyncPlan(logFn, remotePath, added, changed, unchanged, removed, opts.SyncDelete && info.IsDir())
+	if opts.DryRun {
+		logFn("Dry run: no files were changed on the device.", "")
+		return nil
+	}
+
+	var replaced []backupEntry
+	for _, name := range changed {
+		replaced = append(replaced, backupEntry{name: name})
+	}
+	for _, name := range added {
+		replaced = append(replaced, backupEntry{name: strings.TrimSuffix(name, "/")})
+	}
+	for _, name := range removed {
+		replaced = append(replaced, backupEntry{name: name})
+	}
+	if err := backupRemoteFiles(client, ctx, remotePath, opts.BackupName, replaced, logFn); err != nil {
+		return err
+	}
+
+	if len(transfer) > 0 {
+		var total int64
+		for _, entry := range transfer {
+			total += tarEntrySize(entry.fullPath, entry.info, rendered)
+		}
+
+		source := newTarSource(ctx, rendered, opts, logFn)
+		err := extractTarOnDevice(client, ctx, remotePath, opts, total, func(w io.Writer) error {
+			tw := tar.NewWriter(w)
+			for _, entry := range transfer {
+				if err := checkCancellation(ctx); err != nil {
+					return err
+				}
+				if err := source.writeEntry(tw, entry.fullPath, entry.rel, entry.info); err != nil {
+					return err
+				}
+			}
+			return tw.Close()
+		}, logFn)
+		if err != nil {
+			return err
+		}
+		if err := verifyRemoteHashes(client, ctx, remotePath, source.hashes, logFn); err != nil {
+			return err
+		}
+		if err := applyOwnership(client, ctx, remotePath, source.written, opts); err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := deleteRemoteEntries(client, ctx, remotePath, remote, removed); err != nil {
+			return err
+		}
+		logFn(fmt.Sprintf("Deleted %d entries on the device.", len(removed)), "")
+	}
+
+	current := make([]string, 0, len(localNames))
+	for name := range localNames {
+		current = append(current, name)
+	}
+	if err := writeCopyManifest(client, ctx, remotePath, current, deployed, removed); err != nil {
+		return err
+	}
+
+	logFn("Sync complete.", "")
+	return nil
+}
+
+func collectSyncEntries(ctx context.Context, localPath string, info os.FileInfo, rendered map[string][]byte, excludes []string, logFn func(string, string)) ([]syncEntry, error) {
+	var entries []syncEntry
+	add := func(fullPath, rel string, fileInfo os.FileInfo) error {
+		entry := syncEntry{fullPath: fullPath, rel: rel, name: tarEntryName(fullPath, rel, rendered), info: fileInfo}
+		if content, ok := rendered[fullPath]; ok {
+			sum := sha256.Sum256(content)
+			entry.hash = hex.EncodeToString(sum[:])
+		} else if fileInfo.Mode().IsRegular() {
+			hash, err := hashLocalFile(fullPath)
+			if err != nil {
+				return fmt.Errorf("hash '%s': %w", fullPath, err)
+			}
+			entry.hash = hash
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+
+	err := walkCopySource(ctx, localPath, info, excludes, logFn, add)
+	return entries, err
+}
+
+// remoteListCommand lists directories, symlinks and file hashes below remotePath. For a single file
+// only that file is listed.
+func remoteListCommand(remotePath string, isDir bool, fileName string) string {
+	if !isDir {
+		return fmt.Sprintf("cd %s && if [ -f %s ]; then sha256sum %s; fi", shellQuote(remotePath), shellQuote(fileName), shellQuote("./"+fileName))
+	}
+	return fmt.Sprintf("cd %s && find . -mindepth 1 -type d -exec printf 'd %%s\\n' {} + && find . -type l -exec printf 'l %%s\\n' {} + && find . -type f -exec sha256sum {} +", shellQuote(remotePath))
+}
+
+func parseRemoteListing(output string) map[string]remoteEntry {
+	entries := make(map[string]remoteEntry)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "d ./"), strings.HasPrefix(line, "l ./"):
+			entries[line[4:]] = remoteEntry{kind: line[0]}
+		default:
+			hash, name, found := strings.Cut(line, "  ./")
+			if found && len(hash) == sha256.Size*2 {
+				entries[name] = remoteEntry{kind: 'f', hash: hash}
+			}
+		}
+	}
+	return entries
+}
+
+// extraneousRemoteEntries returns the remote entries that an earlier copy deployed and that are missing
+// locally now, without excluded entries and without wago-init's own state. Children sort before their
+// parent directory so the directories are empty by the time they are removed.
+func extraneousRemoteEntries(remotePath string, remote map[string]remoteEntry, localNames, deployed map[string]struct{}, protected *ignoreMatcher) []string {
+	var removed []string
+	for name, entry := range remote {
+		if _, exists := localNames[name]; exists || inStateDir(path.Join(remotePath, name)) {
+			continue
+		}
+		if _, ours := deployed[name]; !ours || protected.excludedWithParents(name, entry.kind == 'd') {
+			continue
+		}
+		removed = append(removed, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(removed)))
+	return removed
+}
+
+// deleteRemoteEntries removes files and symlinks and then the directories in removed. Directories are
+// only removed when empty, so content that wago-init did not deploy survives.
+func deleteRemoteEntries(client *ssh.Client, ctx context.Context, remotePath string, remote map[string]remoteEntry, removed []string) error {
+	var files, dirs []string
+	for _, name := range removed {
+		if remote[name].kind == 'd' {
+			dirs = append(dirs, shellQuote("./"+name))
+		} else {
+			files = append(files, shellQuote("./"+name))
+		}
+	}
+
+	for start := 0; start < len(files); start += remoteBatchSize {
+		end := min(start+remoteBatchSize, len(files))
+		cmd := fmt.Sprintf("cd %s && rm -f -- %s", shellQuote(remotePath), strings.Join(files[start:end], " "))
+		if _, err := runSSHCommandContext(ctx, client, cmd, longSessionTimeout); err != nil {
+			return fmt.Errorf("delete remote files: %w", err)
+		}
+	}
+	for start := 0; start < len(dirs); start += remoteBatchSize {
+		end := min(start+remoteBatchSize, len(dirs))
+		// Directories that still hold foreign content stay; rmdir fails for them.
+		cmd := fmt.Sprintf("cd %s && rmdir -- %s 2>/dev/null; true", shellQuote(remotePath), strings.Join(dirs[start:end], " "))
+		if _, err := runSSHCommandContext(ctx, client, cmd, longSessionTimeout); err != nil {
+			return fmt.Errorf("delete remote directories: %w", err)
+		}
+	}
+	return nil
+}
+
+func logSyncPlan(logFn func(string, string), remotePath string, added, changed, unchanged, removed []string, deleting bool) {
+	summary := fmt.Sprintf("Sync plan for %s: %d new, %d changed, %d unchanged", remotePath, len(added), len(changed), len(unchanged))
+	if deleting {
+		summary += fmt.Sprintf(", %d to delete", len(removed))
+	}
+	logFn(summary, "")
+	for _, name := range added {
+		logFn("  + "+name, "")
+	}
+	for _, name := range changed {
+		logFn("  ~ "+name, "")
+	}
+	for _, name := range removed {
+		logFn("  - "+name, "")
+	}
+}
@@ -0,0 +1,77 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Files with this extension are rendered as text/template in template mode and copied without it.
+const templateExtension = ".tmpl"
+
+// TemplateData is available to *.tmpl config files, e.g. {{.Serial}}, {{.Vars.SITE}} or {{.Profile.IP_ADDRESS}}.
+type TemplateData struct {
+	Serial        string
+	MAC           string
+	IP            string
+	Hostname      string
+	FirmwareBuild string
+	// Vars holds the built-in and custom device variables by name.
+	Vars map[string]string
+	// Profile holds the non-secret station settings by key.
+	Profile map[string]string
+}
+
+func newTemplateData(device DeviceInfo, custom []VariableDefinition, profile map[string]string) TemplateData {
+	return TemplateData{
+		Serial:        device.Serial,
+		MAC:           device.MAC,
+		IP:            device.IP,
+		Hostname:      device.Hostname,
+		FirmwareBuild: device.FirmwareBuild,
+		Vars:          device.Variables(custom),
+		Profile:       profile,
+	}
+}
+
+// renderTemplates renders every *.tmpl file below localPath and returns the results by local path.
+// Rendering happens before anything is sent so a broken template cannot leave a half-copied tree behind.
+func renderTemplates(ctx context.Context, localPath string, info os.FileInfo, data TemplateData, excludes []string, logFn func(string, string)) (map[string][]byte, error) {
+	rendered := make(map[string][]byte)
+
+	render := func(path string) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read template '%s': %w", path, err)
+		}
+		tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("parse template '%s': %w", path, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return fmt.Errorf("render template '%s': %w", path, err)
+		}
+		rendered[path] = out.Bytes()
+		logFn("Rendered template: "+filepath.Base(path), "")
+		return nil
+	}
+
+	// Exclusions are logged by the walk that packages the files.
+	discard := func(string, string) {}
+	err := walkCopySource(ctx, localPath, info, excludes, discard, func(fullPath, _ string, fileInfo os.FileInfo) error {
+		if isTemplateFile(fullPath, fileInfo) {
+			return render(fullPath)
+		}
+		return nil
+	})
+	return rendered, err
+}
+
+func isTemplateFile(path string, info os.FileInfo) bool {
+	return info.Mode().IsRegular() && strings.HasSuffix(path, templateExtension)
+}
@@ -0,0 +1,72 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Mismatches listed in the returned error; the log always lists all of them.
+const maxReportedMismatches = 10
+
+// verifyRemoteHashes computes SHA-256 on the device for every file in expected (name relative to
+// remotePath → hash collected while streaming) and fails if a file is missing or differs.
+func verifyRemoteHashes(client *ssh.Client, ctx context.Context, remotePath string, expected map[string]string, logFn func(string, string)) error {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	logFn(fmt.Sprintf("Verifying %d files on the device", len(names)), "")
+
+	actual := make(map[string]string, len(names))
+	for start := 0; start < len(names); start += remoteBatchSize {
+		end := min(start+remoteBatchSize, len(names))
+		quoted := make([]string, 0, end-start)
+		for _, name := range names[start:end] {
+			quoted = append(quoted, shellQuote("./"+name))
+		}
+		// sha256sum exits non-zero for missing files; those are reported as mismatches below.
+		cmd := fmt.Sprintf("cd %s && sha256sum -- %s 2>/dev/null; true", shellQuote(remotePath), strings.Join(quoted, " "))
+		output, err := runSSHCommandContext(ctx, client, cmd, syncListTimeout)
+		if err != nil {
+			return fmt.Errorf("hash copied files: %w", err)
+		}
+		for name, entry := range parseRemoteListing(output) {
+			actual[name] = entry.hash
+		}
+	}
+
+	var mismatches []string
+	for _, name := range names {
+		hash, found := actual[name]
+		switch {
+		case !found:
+			mismatches = append(mismatches, name+" (missing)")
+		case hash != expected[name]:
+			mismatches = append(mismatches, name+" (hash differs)")
+		}
+	}
+
+	if len(mismatches) == 0 {
+		logFn("Verification passed.", "")
+		return nil
+	}
+
+	for _, mismatch := range mismatches {
+		logFn("Verification failed: "+mismatch, "")
+	}
+	reported := mismatches
+	if len(reported) > maxReportedMismatches {
+		reported = append(reported[:maxReportedMismatches:maxReportedMismatches], fmt.Sprintf("and %d more", len(mismatches)-maxReportedMismatches))
+	}
+	return fmt.Errorf("%d of %d copied files differ on the device: %s", len(mismatches), len(names), strings.Join(reported, ", "))
+}
@@ -1,24 +1,53 @@
 package install
 
-import "context"
+import (
+	"context"
+	"wago-init/internal/registry"
+)
 
 const DefaultIp = "192.168.42.42"
 
 type Parameters struct {
-	Ip                string
-	FirmwareRevision  string
-	NewestFirmware    int
-	FirmwarePath      string
-	ForceFirmware     bool
-	CurrentPassword   string
-	PromptPassword    func() (string, bool)
-	PromptNewPassword func() (string, bool)
-	AWSToken          string
-	AWSEcrUrl         string
-	ContainerImage    string
-	ContainerFlags    string
-	ConfigPath        string
-	Context           context.Context
+	Ip                   string
+	FirmwareRevision     string
+	NewestFirmware       int
+	FirmwarePath         string
+	ForceFirmware        bool
+	FirmwareHealthChecks []string
+	CurrentPassword      string
+	PromptPassword       func() (string, bool)
+	PromptNewPassword    func() (string, bool)
+	Registry             registry.Credentials
+	ContainerMode        string
+	Containers           []ContainerDefinition
+	ComposeFile          string
+	ComposeEnvFile       string
+	ComposeProjectDir    string
+	ImageSource          string
+	ImageTarball         string
+	CopyMappings         []CopyMapping
+	ConfigTemplates      bool
+	TemplateProfile      map[string]string
+	CopySync             bool
+	CopySyncDelete       bool
+	CopyDryRun           bool
+	CopyExcludes         []string
+	CopyCompress         bool
+	CopyCompressionLevel int
+	CopyBackup           bool
+	DockerCleanup        string
+	ConfirmDockerCleanup func(preview string) bool
+	Variables            []VariableDefinition
+	Device               DeviceInfo
+	ArtifactServer       ArtifactServer
+	TransferScheduler    *TransferScheduler
+	TransferWaiting      func(waiting bool)
+	Context              context.Context
+}
+
+// ArtifactServer publishes station-local files over HTTP so devices can download them themselves.
+type ArtifactServer interface {
+	Publish(localPath, deviceIP string) (url string, release func(), err error)
 }
 
 var usersList = []string{"root", "admin", "user"}
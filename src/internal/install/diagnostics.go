@@ -0,0 +1,122 @@
+package install
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	diagnosticsCommandTimeout = 60 * time.Second
+	// Lines of container output included per container.
+	diagnosticsLogTail = 1000
+)
+
+// DefaultDiagnosticsPaths are fetched into a diagnostics bundle unless other paths are chosen.
+var DefaultDiagnosticsPaths = []string{"/var/log"}
+
+// diagnosticsCommand is a command whose output is stored in the bundle under commands/<file>.
+type diagnosticsCommand struct {
+	file string
+	cmd  string
+}
+
+var diagnosticsCommands = []diagnosticsCommand{
+	{file: "fwupdate-status.txt", cmd: firmwareStatusCommand},
+	{file: "firmware.txt", cmd: FirmwareCommand},
+	{file: "docker-ps.txt", cmd: "docker ps -a"},
+	{file: "docker-images.txt", cmd: "docker images"},
+	{file: "dmesg.txt", cmd: "dmesg"},
+	{file: "df.txt", cmd: "df -h"},
+	{file: "uptime.txt", cmd: "uptime"},
+}
+
+// CollectDiagnostics gathers the output of diagnostics commands, the logs of every container and the
+// given remote paths from the device into localPath, a .tar.gz file or a folder (see CopyPathFromDevice).
+// Command outputs go to commands/, container logs to docker-logs/ and fetched paths to files/. A failing
+// command or path is recorded and logged but does not stop the collection.
+func CollectDiagnostics(client *ssh.Client, ctx context.Context, localPath string, paths []string, opts CopyOptions, logFn func(string, string)) (err error) {
+	ctx = contextOrBackground(ctx)
+	if err := checkCancellation(ctx); err != nil {
+		return err
+	}
+	localPath = filepath.Clean(strings.TrimSpace(localPath))
+	if localPath == "." {
+		return errors.New("local path must not be empty")
+	}
+
+	target, err := openFetchTarget(localPath, logFn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, target.Close())
+	}()
+
+	logFn("Collecting diagnostics to "+localPath, "")
+
+	for _, command := range diagnosticsCommands {
+		if err := collectCommandOutput(client, ctx, target, path.Join("commands", command.file), command.cmd, logFn); err != nil {
+			return err
+		}
+	}
+
+	names, err := runSSHCommandContext(ctx, client, "docker ps -a --format '{{.Names}}'", shortSessionTimeout)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		logFn(fmt.Sprintf("Warning: could not list containers: %v", err), "")
+	}
+	for _, name := range strings.Fields(names) {
+		cmd := fmt.Sprintf("docker logs --timestamps --tail %d %s", diagnosticsLogTail, shellQuote(name))
+		if err := collectCommandOutput(client, ctx, target, path.Join("docker-logs", name+".txt"), cmd, logFn); err != nil {
+			return err
+		}
+	}
+
+	for _, remotePath := range paths {
+		remotePath = path.Clean(strings.TrimSpace(remotePath))
+		logFn("Fetching "+remotePath, "")
+		prefix := path.Join("files", strings.TrimPrefix(path.Dir(remotePath), "/"))
+		if err := fetchRemotePath(client, ctx, remotePath, prefix, target, opts, logFn); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			logFn(fmt.Sprintf("Warning: could not fetch %s: %v", remotePath, err), "")
+		}
+	}
+
+	logFn("Diagnostics collected.", "")
+	return nil
+}
+
+// collectCommandOutput runs cmd on the device and stores stdout and stderr as name. A failing command
+// stores its error instead.
+func collectCommandOutput(client *ssh.Client, ctx context.Context, target fetchTarget, name, cmd string, logFn func(string, string)) error {
+	output, err := runSSHCommandContext(ctx, client, cmd+" 2>&1", diagnosticsCommandTimeout)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		logFn(fmt.Sprintf("Warning: %s failed: %v", cmd, err), "")
+		output = fmt.Sprintf("%s\n\ncommand failed: %v", output, err)
+	}
+
+	content := fmt.Sprintf("$ %s\n%s\n", cmd, output)
+	header := &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0o644,
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+	}
+	return target.add(header, strings.NewReader(content))
+}
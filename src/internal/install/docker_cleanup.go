@@ -0,0 +1,167 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Docker cleanup policies applied before containers are deployed.
+const (
+	DockerCleanupWipeAll     = "wipe-all"
+	DockerCleanupManaged     = "managed"
+	DockerCleanupKeepVolumes = "keep-volumes"
+	DockerCleanupNone        = "none"
+)
+
+// ManagedContainerLabel marks containers created by wago-init so that the managed cleanup policy only
+// removes those and leaves containers deployed by other means untouched.
+const ManagedContainerLabel = "wago-init.managed=true"
+
+// composeManagedLabel identifies containers of the compose project wago-init deploys. Compose sets it on
+// every container it creates, so they count as managed without a label of their own.
+const composeManagedLabel = "com.docker.compose.project.working_dir=" + composeRemoteDir
+
+const (
+	dockerListFormat = "{{.ID}}\t{{.Names}} ({{.Image}})"
+	dockerImageList  = "docker images -a --format '{{.ID}}\t{{.Repository}}:{{.Tag}}'"
+)
+
+// dockerObject is a container or image found on the device, with a readable description for the preview.
+type dockerObject struct {
+	id          string
+	description string
+}
+
+// CleanupDocker removes containers and images from the device according to policy. It logs a preview
+// of everything that will be removed and, when confirm is set, only removes it once confirm accepted the
+// preview. An empty policy keeps the historic wipe-all. Objects docker fails to remove are logged and the
+// cleanup carries on.
+func CleanupDocker(ctx context.Context, client *ssh.Client, logFn func(string, string), policy string, confirm func(preview string) bool) error {
+	if policy == "" {
+		policy = DockerCleanupWipeAll
+	}
+
+	listContainers := "docker ps -a --format " + shellQuote(dockerListFormat)
+	var (
+		removeFlags  = "-f"
+		removeImages bool
+	)
+	switch policy {
+	case DockerCleanupNone:
+		logFn("Docker cleanup disabled, keeping existing containers, images and volumes.", "")
+		return nil
+	case DockerCleanupWipeAll:
+		removeFlags = "-vf"
+		removeImages = true
+	case DockerCleanupKeepVolumes:
+		removeImages = true
+	case DockerCleanupManaged:
+		// docker ps combines label filters with AND, so each label needs its own listing.
+		listContainers = fmt.Sprintf("docker ps -a --filter %s --format %s; docker ps -a --filter %s --format %s",
+			shellQuote("label="+ManagedContainerLabel), shellQuote(dockerListFormat),
+			shellQuote("label="+composeManagedLabel), shellQuote(dockerListFormat))
+	default:
+		return fmt.Errorf("unknown docker cleanup policy '%s'", policy)
+	}
+
+	containers, err := listDockerObjects(ctx, client, listContainers)
+	if err != nil {
+		return fmt.Errorf("list containers: %w", err)
+	}
+	var images []dockerObject
+	if removeImages {
+		if images, err = listDockerObjects(ctx, client, dockerImageList); err != nil {
+			return fmt.Errorf("list images: %w", err)
+		}
+	}
+
+	preview := cleanupPreview(policy, containers, images)
+	for _, line := range preview {
+		logFn(line, "")
+	}
+	if len(containers) == 0 && len(images) == 0 {
+		return nil
+	}
+	if confirm != nil && !confirm(strings.Join(preview, "\n")) {
+		logFn("Docker cleanup declined, aborting installation.", "")
+		return fmt.Errorf("docker cleanup declined: %w", context.Canceled)
+	}
+
+	// docker rm and rmi remove every object they can and fail only for the rest, e.g. an image still used
+	// by a container another tool keeps running.
+	if len(containers) > 0 {
+		cmd := fmt.Sprintf("docker rm %s %s", removeFlags, strings.Join(objectIDs(containers), " "))
+		if _, err := runSSHCommandContext(ctx, client, cmd, longSessionTimeout); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logFn("Warning: some containers could not be removed: "+err.Error(), "")
+		}
+	}
+	if len(images) > 0 {
+		cmd := "docker rmi -f " + strings.Join(objectIDs(images), " ")
+		if _, err := runSSHCommandContext(ctx, client, cmd, longSessionTimeout); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logFn("Warning: some images could not be removed: "+err.Error(), "")
+		}
+	}
+
+	return nil
+}
+
+func listDockerObjects(ctx context.Context, client *ssh.Client, cmd string) ([]dockerObject, error) {
+	output, err := runSSHCommandContext(ctx, client, cmd, shortSessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []dockerObject
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(output, "\n") {
+		id, description, _ := strings.Cut(strings.TrimSpace(line), "\t")
+		if id == "" {
+			continue
+		}
+		// docker images -a lists an image once per tag.
+		if _, exists := seen[id]; exists {
+			continue
+		}
+		seen[id] = struct{}{}
+		objects = append(objects, dockerObject{id: id, description: description})
+	}
+	return objects, nil
+}
+
+// cleanupPreview describes what the cleanup removes, one line per object.
+func cleanupPreview(policy string, containers, images []dockerObject) []string {
+	if len(containers) == 0 && len(images) == 0 {
+		return []string{fmt.Sprintf("Docker cleanup (%s): nothing to remove.", policy)}
+	}
+
+	lines := []string{fmt.Sprintf("Docker cleanup (%s) will remove %d container(s) and %d image(s):", policy, len(containers), len(images))}
+	for _, c := range containers {
+		lines = append(lines, "  container "+c.description)
+	}
+	for _, image := range images {
+		lines = append(lines, "  image "+image.description)
+	}
+	if policy == DockerCleanupWipeAll {
+		lines = append(lines, "  anonymous volumes of the removed containers")
+	} else {
+		lines = append(lines, "  volumes are kept")
+	}
+	return lines
+}
+
+func objectIDs(objects []dockerObject) []string {
+	ids := make([]string, len(objects))
+	for i, object := range objects {
+		ids[i] = shellQuote(object.id)
+	}
+	return ids
+}
@@ -0,0 +1,86 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"wago-init/internal/fs"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	firmwareHealthCheckTimeout = 2 * time.Minute
+	// Services are still starting right after the reboot, so the first attempt waits a little and a failed
+	// attempt is repeated before the firmware is rolled back.
+	firmwareHealthSettleTime    = 30 * time.Second
+	firmwareHealthAttempts      = 5
+	firmwareHealthRetryInterval = 20 * time.Second
+)
+
+// DefaultFirmwareHealthChecks are used when no health check commands are configured. They verify that the
+// config tools respond and that the docker daemon is running and accepts requests.
+var DefaultFirmwareHealthChecks = []string{
+	"/etc/config-tools/get_coupler_details firmware-revision",
+	"/etc/config-tools/get_typelabel_value -n UII",
+	"docker info >/dev/null",
+}
+
+// ParseHealthChecks converts the stored multiline health check value into one command per line.
+// Empty lines and lines starting with '#' are ignored.
+func ParseHealthChecks(raw string) []string {
+	decoded := strings.ReplaceAll(fs.DecodeMultilineValue(raw), "\r\n", "\n")
+
+	var commands []string
+	for _, line := range strings.Split(decoded, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		commands = append(commands, trimmed)
+	}
+	return commands
+}
+
+// RunFirmwareHealthCheck waits for the device to settle and then runs every command on it. An attempt
+// fails on the first non-zero exit status; the check fails once firmwareHealthAttempts attempts failed.
+func RunFirmwareHealthCheck(ctx context.Context, client *ssh.Client, commands []string, logFn func(string, string)) error {
+	if len(commands) == 0 {
+		commands = DefaultFirmwareHealthChecks
+	}
+
+	logFn(fmt.Sprintf("Waiting %s for services to start before the health check", firmwareHealthSettleTime), "")
+	if err := sleepWithContext(ctx, firmwareHealthSettleTime); err != nil {
+		return err
+	}
+
+	var err error
+	for attempt := 1; attempt <= firmwareHealthAttempts; attempt++ {
+		if err = runHealthCheckCommands(ctx, client, commands, logFn); err == nil {
+			logFn("Health check passed", "")
+			return nil
+		}
+		if ctxErr := checkCancellation(ctx); ctxErr != nil {
+			return ctxErr
+		}
+		if attempt == firmwareHealthAttempts {
+			break
+		}
+		logFn(fmt.Sprintf("Health check attempt %d/%d failed: %v; retrying in %s", attempt, firmwareHealthAttempts, err, firmwareHealthRetryInterval), "")
+		if err := sleepWithContext(ctx, firmwareHealthRetryInterval); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("%w (after %d attempts)", err, firmwareHealthAttempts)
+}
+
+func runHealthCheckCommands(ctx context.Context, client *ssh.Client, commands []string, logFn func(string, string)) error {
+	for i, cmd := range commands {
+		logFn(fmt.Sprintf("Health check %d/%d: %s", i+1, len(commands), cmd), "")
+		if _, err := runSSHCommandContext(ctx, client, cmd, firmwareHealthCheckTimeout); err != nil {
+			return fmt.Errorf("health check '%s': %w", cmd, err)
+		}
+	}
+	return nil
+}
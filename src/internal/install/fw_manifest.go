@@ -0,0 +1,86 @@
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The manifest records which package was extracted into firmwareRemoteDir:
+//
+//	sha256=<hex digest of the .wup file>
+//
+// firmwareChecksumsPath lists the sha256sum of every extracted file, so a retry can verify the content
+// before it skips the upload.
+const (
+	firmwareManifestPath  = firmwareRemoteDir + "/.wago-init-manifest"
+	firmwareChecksumsPath = firmwareRemoteDir + "/.wago-init-files.sha256"
+)
+
+// firmwarePackageReusable reports whether the device already holds the extracted package with the given
+// hash and every extracted file still matches its recorded checksum.
+func firmwarePackageReusable(ctx context.Context, client *ssh.Client, packageHash string) (bool, error) {
+	output, err := runSSHCommandContext(ctx, client, fmt.Sprintf("cat %s 2>/dev/null || true", shellQuote(firmwareManifestPath)), shortSessionTimeout)
+	if err != nil {
+		return false, fmt.Errorf("read firmware manifest: %w", err)
+	}
+
+	manifest := parseFirmwareManifest(output)
+	if manifest["sha256"] != packageHash {
+		return false, nil
+	}
+
+	verifyCmd := fmt.Sprintf("cd %s && [ -s %s ] && sha256sum -c %s >/dev/null",
+		shellQuote(firmwareRemoteDir), shellQuote(firmwareChecksumsPath), shellQuote(firmwareChecksumsPath))
+	if _, err := runSSHCommandContext(ctx, client, verifyCmd, longSessionTimeout); err != nil {
+		if ctxErr := checkCancellation(ctx); ctxErr != nil {
+			return false, ctxErr
+		}
+		// A missing or modified file means the package has to be extracted again.
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeFirmwareManifest hashes the extracted files and records them together with the package hash. The
+// manifest is written last, so an interrupted write never leaves a manifest without checksums.
+func writeFirmwareManifest(ctx context.Context, client *ssh.Client, packageHash string) error {
+	cmd := fmt.Sprintf("cd %s && find . -type f ! -name %s ! -name %s -exec sha256sum {} + > %s && printf 'sha256=%%s\\n' %s > %s",
+		shellQuote(firmwareRemoteDir), shellQuote(path.Base(firmwareManifestPath)), shellQuote(path.Base(firmwareChecksumsPath)),
+		shellQuote(firmwareChecksumsPath), shellQuote(packageHash), shellQuote(firmwareManifestPath))
+	_, err := runSSHCommandContext(ctx, client, cmd, longSessionTimeout)
+	return err
+}
+
+func parseFirmwareManifest(raw string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(raw, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return values
+}
+
+func hashLocalFile(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -2,6 +2,7 @@ package install
 
 import (
 	"archive/zip"
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -15,23 +16,36 @@ import (
 )
 
 const (
-	firmwareRemoteDir            = "/home/update"
-	firmwareStartCommand         = "/etc/config-tools/fwupdate start --path"
-	firmwareActivateCommand      = "/etc/config-tools/fwupdate activate [--keep-application]"
-	firmwareCancelCommand        = "/etc/config-tools/fwupdate cancel"
-	firmwareStatusCommand        = "/etc/config-tools/fwupdate status"
-	firmwareFinishCommand        = "/etc/config-tools/fwupdate finish"
-	firmwareReconnectTimeout     = 6 * time.Minute
-	firmwareReconnectInterval    = 10 * time.Second
-	firmwareUnzipTimeout         = 2 * time.Minute
-	firmwareActivateTimeout      = 5 * time.Minute
-	firmwareStartTimeout         = 15 * time.Minute
-	firmwareFinishTimeout        = 5 * time.Minute
-	firmwareLogPollIntervalLong  = 10 * time.Second
-	firmwareLogPollIntervalShort = 5 * time.Second
+	firmwareRemoteDir             = "/home/update"
+	firmwareStartCommand          = "/etc/config-tools/fwupdate start --path"
+	firmwareActivateCommand       = "/etc/config-tools/fwupdate activate [--keep-application]"
+	firmwareCancelCommand         = "/etc/config-tools/fwupdate cancel"
+	firmwareStatusCommand         = "/etc/config-tools/fwupdate status"
+	firmwareFinishCommand         = "/etc/config-tools/fwupdate finish"
+	firmwareRevertCommand         = "/etc/config-tools/fwupdate revert"
+	firmwareReconnectTimeout      = 6 * time.Minute
+	firmwareReconnectInterval     = 10 * time.Second
+	firmwareUnzipTimeout          = 2 * time.Minute
+	firmwareActivateTimeout       = 5 * time.Minute
+	firmwareStartTimeout          = 15 * time.Minute
+	firmwareFinishTimeout         = 5 * time.Minute
+	firmwareCancelTimeout         = 2 * time.Minute
+	firmwareInitializationTimeout = 5 * time.Minute
+	firmwareProgressTimeout       = 30 * time.Minute
+	firmwareFinalizationTimeout   = 15 * time.Minute
+	firmwareLogPollIntervalLong   = 10 * time.Second
+	firmwareLogPollIntervalShort  = 5 * time.Second
+	firmwareRollbackTimeout       = 5 * time.Minute
+)
+
+const (
+	firmwareStateUnconfirmed = "unconfirmed"
+	firmwareStateIdle        = "idle"
+	firmwareStateFinished    = "finished"
 )
 
 func UpdateFirmware(client *ssh.Client, logFn func(string, string), params *Parameters, progressFn func(float64, float64)) (*ssh.Client, error) {
+	ctx := contextOrBackground(params.Context)
 
 	progressFn(0.01, 0.07)
 
@@ -43,52 +57,68 @@ func UpdateFirmware(client *ssh.Client, logFn func(string, string), params *Para
 		return client, err
 	}
 
-	if _, err := runSSHCommand(client, "rm -rf /home/update/* && mkdir -p /home/update", longSessionTimeout); err != nil {
-		return client, fmt.Errorf("prepare remote firmware directory: %w", err)
+	// The revision before the update tells whether a rollback really brought the previous firmware back.
+	previousRevision, err := readFirmwareRevision(ctx, client)
+	if err != nil {
+		logFn("Could not read the current firmware revision: "+err.Error(), "")
 	}
 
-	logFn("Uploading firmware package to device", "")
-	if err := CopyPathToDevice(client, params.Context, localPath, firmwareRemoteDir, logFn); err != nil {
-		return client, fmt.Errorf("upload firmware: %w", err)
+	packageHash, err := hashLocalFile(localPath)
+	if err != nil {
+		return client, fmt.Errorf("hash firmware package: %w", err)
 	}
 
-	progressFn(0.08, 0.08)
-
-	remoteFileName := filepath.Base(localPath)
-	remoteFilePath := path.Join(firmwareRemoteDir, remoteFileName)
-
-	unzipCmd := fmt.Sprintf("cd %s && unzip -o %s", shellQuote(firmwareRemoteDir), shellQuote(remoteFileName))
-	logFn("Extracting firmware package on device", "")
-	if err := runSSHCommandStreaming(client, unzipCmd, firmwareUnzipTimeout, logFn); err != nil {
-		return client, fmt.Errorf("unzip firmware: %w", err)
+	reusable, err := firmwarePackageReusable(ctx, client, packageHash)
+	if err != nil {
+		return client, err
 	}
-
-	if _, err := runSSHCommand(client, fmt.Sprintf("rm -f %s", shellQuote(remoteFilePath)), shortSessionTimeout); err != nil {
-		return client, fmt.Errorf("cleanup firmware archive: %w", err)
+	if reusable {
+		logFn("Identical firmware package already extracted on device, skipping upload", "")
+		progressFn(0.08, 0.08)
+	} else if err := prepareFirmwarePackage(ctx, client, params, localPath, packageHash, logFn, progressFn); err != nil {
+		return client, err
 	}
 
 	progressFn(0.09, 0.09)
 
+	// Until "fwupdate start" has been issued the device only holds a prepared update,
+	// so aborting with "fwupdate cancel" leaves it on the running firmware.
 	logFn("Activating firmware daemon", "")
-	if err := runSSHCommandStreaming(client, firmwareActivateCommand, firmwareActivateTimeout, logFn); err != nil {
-		runSSHCommandStreaming(client, firmwareCancelCommand, firmwareActivateTimeout, logFn)
+	if err := runSSHCommandStreamingContext(ctx, client, firmwareActivateCommand, firmwareActivateTimeout, logFn); err != nil {
+		cancelFirmwareUpdate(client, logFn)
 		return client, fmt.Errorf("fwupdate activate: %w", err)
 	}
 
-	if err := monitorFirmwareInitialization(client); err != nil {
+	if err := monitorFirmwareInitialization(ctx, client); err != nil {
+		cancelFirmwareUpdate(client, logFn)
 		return client, err
 	}
 
 	progressFn(0.10, 0.30)
 
+	if err := checkCancellation(ctx); err != nil {
+		cancelFirmwareUpdate(client, logFn)
+		return client, err
+	}
+
+	// Killing "fwupdate start" halfway could leave the device without a consistent image, so the start
+	// phase ignores cancellation and the session stops only once the command returned.
 	startCmd := fmt.Sprintf("%s %s", firmwareStartCommand, firmwareRemoteDir)
-	startErr := runSSHCommandStreaming(client, startCmd, firmwareStartTimeout, logFn)
+	stopNotice := context.AfterFunc(ctx, func() {
+		logFn("Cancel requested; waiting for fwupdate start to finish because interrupting it is not safe", "")
+	})
+	startErr := runSSHCommandStreamingContext(context.WithoutCancel(ctx), client, startCmd, firmwareStartTimeout, logFn)
+	stopNotice()
 	if startErr != nil {
-		runSSHCommandStreaming(client, firmwareCancelCommand, firmwareActivateTimeout, logFn)
+		cancelFirmwareUpdate(client, logFn)
 		return client, fmt.Errorf("fwupdate start: %w", startErr)
 	}
+	if err := checkCancellation(ctx); err != nil {
+		logFn("Firmware update is being applied; the device completes it and reboots on its own", "")
+		return client, err
+	}
 	logFn("Firmware update initiated, monitoring device status...", "")
-	if err := monitorFirmwareProgress(client, logFn); err != nil {
+	if err := monitorFirmwareProgress(ctx, client, logFn); err != nil {
 		return client, err
 	}
 
@@ -99,7 +129,7 @@ func UpdateFirmware(client *ssh.Client, logFn func(string, string), params *Para
 	_ = client.Close()
 
 	logFn("Waiting for device to come back online after reboot...", "")
-	newClient, newPassword, err := reconnectAfterFirmware(params, logFn)
+	newClient, newPassword, err := reconnectAfterFirmware(ctx, params, logFn)
 	if err != nil {
 		return client, err
 	}
@@ -107,13 +137,38 @@ func UpdateFirmware(client *ssh.Client, logFn func(string, string), params *Para
 
 	progressFn(0.59, 0.59)
 
-	if err := monitorFirmwareFinalization(newClient, logFn); err != nil {
-		return client, err
+	state, err := monitorFirmwareFinalization(ctx, newClient, logFn)
+	if err != nil {
+		return newClient, err
 	}
 
-	logFn("Finalising firmware update", "")
-	if err := runSSHCommandStreaming(newClient, firmwareFinishCommand, firmwareFinishTimeout, logFn); err != nil {
-		return newClient, fmt.Errorf("fwupdate finish: %w", err)
+	if state == firmwareStateUnconfirmed {
+		logFn("New firmware is running unconfirmed, checking device health before confirming", "")
+		// Like the start phase, finish and revert ignore cancellation: the session must not stop with the
+		// new firmware left unconfirmed. A health check cut short by a cancel is treated as failed.
+		if err := RunFirmwareHealthCheck(ctx, newClient, params.FirmwareHealthChecks, logFn); err != nil {
+			cancelled := ctx.Err() != nil
+			if cancelled {
+				logFn("Cancel requested during the health check, rolling back to the previous firmware before stopping", "")
+			} else {
+				logFn("Health check failed, rolling back to the previous firmware", "")
+			}
+			rolledBack, rollbackErr := rollbackFirmware(context.WithoutCancel(ctx), newClient, params, previousRevision, logFn)
+			switch {
+			case rollbackErr != nil:
+				return rolledBack, fmt.Errorf("firmware health check failed (%v) and rollback failed: %w", err, rollbackErr)
+			case cancelled:
+				return rolledBack, err
+			}
+			return rolledBack, fmt.Errorf("firmware health check failed, device rolled back to previous firmware: %w", err)
+		}
+
+		logFn("Finalising firmware update", "")
+		if err := runSSHCommandStreamingContext(context.WithoutCancel(ctx), newClient, firmwareFinishCommand, firmwareFinishTimeout, logFn); err != nil {
+			return newClient, fmt.Errorf("fwupdate finish: %w", err)
+		}
+	} else {
+		logFn(fmt.Sprintf("Firmware daemon reports status=%s, no confirmation required", state), "")
 	}
 
 	stillRequired, err := CheckFirmware(newClient, logFn, params.NewestFirmware)
@@ -129,6 +184,139 @@ func UpdateFirmware(client *ssh.Client, logFn func(string, string), params *Para
 	return newClient, nil
 }
 
+// prepareFirmwarePackage clears firmwareRemoteDir, uploads and extracts the package and records it in the
+// firmware manifest so a retried session can reuse it.
+func prepareFirmwarePackage(ctx context.Context, client *ssh.Client, params *Parameters, localPath, packageHash string, logFn func(string, string), progressFn func(float64, float64)) error {
+	prepareCmd := fmt.Sprintf("rm -rf %s/* %s %s && mkdir -p %s", firmwareRemoteDir, shellQuote(firmwareManifestPath), shellQuote(firmwareChecksumsPath), shellQuote(firmwareRemoteDir))
+	if _, err := runSSHCommandContext(ctx, client, prepareCmd, longSessionTimeout); err != nil {
+		return fmt.Errorf("prepare remote firmware directory: %w", err)
+	}
+
+	if err := uploadFirmwarePackage(ctx, client, params, localPath, logFn, progressRange(progressFn, 0.01, 0.07)); err != nil {
+		return fmt.Errorf("upload firmware: %w", err)
+	}
+
+	progressFn(0.08, 0.08)
+
+	remoteFileName := filepath.Base(localPath)
+	remoteFilePath := path.Join(firmwareRemoteDir, remoteFileName)
+
+	unzipCmd := fmt.Sprintf("cd %s && unzip -o %s", shellQuote(firmwareRemoteDir), shellQuote(remoteFileName))
+	logFn("Extracting firmware package on device", "")
+	if err := runSSHCommandStreamingContext(ctx, client, unzipCmd, firmwareUnzipTimeout, logFn); err != nil {
+		return fmt.Errorf("unzip firmware: %w", err)
+	}
+
+	if _, err := runSSHCommandContext(ctx, client, fmt.Sprintf("rm -f %s", shellQuote(remoteFilePath)), shortSessionTimeout); err != nil {
+		return fmt.Errorf("cleanup firmware archive: %w", err)
+	}
+
+	if err := writeFirmwareManifest(ctx, client, packageHash); err != nil {
+		logFn("Could not record firmware manifest: "+err.Error(), "")
+	}
+	return nil
+}
+
+// uploadFirmwarePackage places the firmware archive in firmwareRemoteDir. When an artifact server is
+// configured the device downloads the file itself, otherwise it is streamed over the SSH connection.
+func uploadFirmwarePackage(ctx context.Context, client *ssh.Client, params *Parameters, localPath string, logFn func(string, string), progress func(float64)) error {
+	release, err := params.TransferScheduler.Acquire(ctx, "firmware upload", logFn, params.TransferWaiting)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if params.ArtifactServer != nil {
+		err := downloadFromArtifactServer(ctx, client, params.ArtifactServer, params.Ip, localPath, firmwareRemoteDir, logFn)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		logFn("HTTP download failed, falling back to SSH upload: "+err.Error(), "")
+	}
+
+	logFn("Uploading firmware package to device", "")
+	opts := CopyOptions{
+		Compress:         params.CopyCompress,
+		CompressionLevel: params.CopyCompressionLevel,
+		Progress:         progress,
+	}
+	return CopyPathToDevice(client, ctx, localPath, firmwareRemoteDir, opts, logFn)
+}
+
+// cancelFirmwareUpdate asks the firmware daemon to drop a prepared update. It deliberately ignores the
+// session context because it usually runs after that context has been cancelled.
+func cancelFirmwareUpdate(client *ssh.Client, logFn func(string, string)) {
+	logFn("Cancelling pending firmware update on device", "")
+	if err := runSSHCommandStreaming(client, firmwareCancelCommand, firmwareCancelTimeout, logFn); err != nil {
+		logFn("fwupdate cancel failed: "+err.Error(), "")
+	}
+}
+
+// rollbackFirmware discards the unconfirmed firmware with "fwupdate revert", which reboots the device into
+// the previously installed image. It then reconnects and checks that the device runs previousRevision
+// again. The returned client is the one to use afterwards; client itself is closed once the device went
+// down.
+func rollbackFirmware(ctx context.Context, client *ssh.Client, params *Parameters, previousRevision string, logFn func(string, string)) (*ssh.Client, error) {
+	err := runSSHCommandStreaming(client, firmwareRevertCommand, firmwareRollbackTimeout, logFn)
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) && exitErr.Signal() == "" {
+		return client, fmt.Errorf("fwupdate revert: %w", err)
+	}
+	// Any other error is the command or connection being torn down because the device already reboots.
+	logFn("Rollback triggered, waiting for the device to reboot into the previous firmware", "")
+	if err := waitForDisconnect(ctx, client, firmwareRollbackTimeout); err != nil {
+		return client, fmt.Errorf("fwupdate revert: %w", err)
+	}
+	_ = client.Close()
+
+	newClient, newPassword, err := reconnectAfterFirmware(ctx, params, logFn)
+	if err != nil {
+		return client, fmt.Errorf("reconnect after rollback: %w", err)
+	}
+	params.CurrentPassword = newPassword
+
+	revision, err := readFirmwareRevision(ctx, newClient)
+	if err != nil {
+		return newClient, fmt.Errorf("verify firmware after rollback: %w", err)
+	}
+	if previousRevision != "" && revision != previousRevision {
+		return newClient, fmt.Errorf("device runs firmware %s after rollback, expected %s", revision, previousRevision)
+	}
+	logFn("Device runs the previous firmware again: "+revision, "")
+	return newClient, nil
+}
+
+// waitForDisconnect polls the device until the SSH connection drops, which marks the start of a reboot.
+func waitForDisconnect(parent context.Context, client *ssh.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	for {
+		if _, err := runSSHCommandContext(ctx, client, "true", shortSessionTimeout); err != nil {
+			if ctx.Err() != nil {
+				return timeoutError(parent, "device did not go down for the reboot", timeout, ctx.Err())
+			}
+			return nil
+		}
+		if err := sleepWithContext(ctx, firmwareLogPollIntervalShort); err != nil {
+			return timeoutError(parent, "device did not go down for the reboot", timeout, err)
+		}
+	}
+}
+
+// readFirmwareRevision returns the firmware revision string reported by the device.
+func readFirmwareRevision(ctx context.Context, client *ssh.Client) (string, error) {
+	output, err := runSSHCommandContext(ctx, client, FirmwareCommand, shortSessionTimeout)
+	if err != nil {
+		return "", err
+	}
+	revision, _ := parseFirmwareBuild(output)
+	if revision == "" {
+		return "", errors.New("firmware output empty")
+	}
+	return revision, nil
+}
+
 func validateFirmwareFile(localPath string) error {
 	info, err := os.Stat(localPath)
 	if err != nil {
@@ -151,58 +339,82 @@ func validateFirmwareFile(localPath string) error {
 	return nil
 }
 
-func monitorFirmwareInitialization(client *ssh.Client) error {
+func monitorFirmwareInitialization(parent context.Context, client *ssh.Client) error {
+	ctx, cancel := context.WithTimeout(parent, firmwareInitializationTimeout)
+	defer cancel()
+
 	for {
-		output, err := runSSHCommand(client, firmwareStatusCommand, longSessionTimeout)
+		output, err := runSSHCommandContext(ctx, client, firmwareStatusCommand, longSessionTimeout)
 		if err != nil {
-			return err
+			return timeoutError(parent, "firmware initialization did not complete", firmwareInitializationTimeout, err)
 		}
 		if strings.Contains(strings.ToLower(output), "status=prepared") {
 			return nil
 		}
-		time.Sleep(firmwareLogPollIntervalShort)
+		if err := sleepWithContext(ctx, firmwareLogPollIntervalShort); err != nil {
+			return timeoutError(parent, "firmware initialization did not complete", firmwareInitializationTimeout, err)
+		}
 	}
 }
 
-func monitorFirmwareProgress(client *ssh.Client, logFn func(string, string)) error {
+func monitorFirmwareProgress(parent context.Context, client *ssh.Client, logFn func(string, string)) error {
+	ctx, cancel := context.WithTimeout(parent, firmwareProgressTimeout)
+	defer cancel()
+
 	for {
-		output, err := runSSHCommand(client, firmwareStatusCommand, longSessionTimeout)
+		output, err := runSSHCommandContext(ctx, client, firmwareStatusCommand, longSessionTimeout)
 		if err != nil {
+			if ctx.Err() != nil {
+				return timeoutError(parent, "firmware update did not complete", firmwareProgressTimeout, ctx.Err())
+			}
 			logFn("Stopped receiving firmware status updates; device is likely rebooting.", "")
 			return nil
 		}
 
 		lines := strings.Split(strings.TrimSpace(output), "\n")
-
-		result := fmt.Sprintf("Update status: %s, %s, %s, %s", lines[2], lines[3], lines[4], lines[5])
-		logFn(result, "Update status: ")
+		if len(lines) >= 6 {
+			result := fmt.Sprintf("Update status: %s, %s, %s, %s", lines[2], lines[3], lines[4], lines[5])
+			logFn(result, "Update status: ")
+		}
 
 		if strings.Contains(output, "status=error") {
-			return fmt.Errorf("firmware update reported error: %s", lines[5])
+			return fmt.Errorf("firmware update reported error: %s", lines[len(lines)-1])
 		}
 
-		time.Sleep(firmwareLogPollIntervalShort)
+		if err := sleepWithContext(ctx, firmwareLogPollIntervalShort); err != nil {
+			return timeoutError(parent, "firmware update did not complete", firmwareProgressTimeout, err)
+		}
 	}
 }
 
-func monitorFirmwareFinalization(client *ssh.Client, logFn func(string, string)) error {
+// monitorFirmwareFinalization waits until the firmware daemon settles after the reboot and returns the
+// reported state (firmwareStateUnconfirmed, firmwareStateIdle or firmwareStateFinished).
+func monitorFirmwareFinalization(parent context.Context, client *ssh.Client, logFn func(string, string)) (string, error) {
 	const maxTransientErrors = 6
 
+	ctx, cancel := context.WithTimeout(parent, firmwareFinalizationTimeout)
+	defer cancel()
+
 	var (
 		errorCount int
 	)
 
 	for {
-		time.Sleep(firmwareLogPollIntervalShort)
+		if err := sleepWithContext(ctx, firmwareLogPollIntervalShort); err != nil {
+			return "", timeoutError(parent, "firmware finalization did not complete", firmwareFinalizationTimeout, err)
+		}
 
-		output, err := runSSHCommand(client, firmwareStatusCommand, longSessionTimeout)
+		output, err := runSSHCommandContext(ctx, client, firmwareStatusCommand, longSessionTimeout)
 		if err != nil {
+			if ctx.Err() != nil {
+				return "", timeoutError(parent, "firmware finalization did not complete", firmwareFinalizationTimeout, ctx.Err())
+			}
 			errorCount++
 			if errorCount > maxTransientErrors {
-				return fmt.Errorf("monitor firmware finalization: %w", err)
+				return "", fmt.Errorf("monitor firmware finalization: %w", err)
 			}
 			logFn(fmt.Sprintf("Lost connection while checking firmware status (%d/%d); retrying...", errorCount, maxTransientErrors), "")
-			time.Sleep(firmwareLogPollIntervalShort)
+			continue
 		}
 
 		errorCount = 0
@@ -217,24 +429,30 @@ func monitorFirmwareFinalization(client *ssh.Client, logFn func(string, string))
 			lower := strings.ToLower(trimmed)
 
 			if strings.Contains(lower, "status=error") {
-				return fmt.Errorf("firmware update finalization reported error: %s", trimmed)
+				return "", fmt.Errorf("firmware update finalization reported error: %s", trimmed)
 			}
 
-			if strings.Contains(lower, "status=unconfirmed") || strings.Contains(lower, "status=idle") || strings.Contains(lower, "status=finished") {
-				return nil
+			for _, state := range []string{firmwareStateUnconfirmed, firmwareStateIdle, firmwareStateFinished} {
+				if strings.Contains(lower, "status="+state) {
+					return state, nil
+				}
 			}
 		}
 	}
 }
 
-func reconnectAfterFirmware(params *Parameters, logFn func(string, string)) (*ssh.Client, string, error) {
+func reconnectAfterFirmware(ctx context.Context, params *Parameters, logFn func(string, string)) (*ssh.Client, string, error) {
 	deadline := time.Now().Add(firmwareReconnectTimeout)
 	password := params.CurrentPassword
 	addr := net.JoinHostPort(params.Ip, "22")
 
 	for time.Now().Before(deadline) {
+		if err := checkCancellation(ctx); err != nil {
+			return nil, password, err
+		}
+
 		if password != "" {
-			client, err := dialSSH(addr, password)
+			client, err := dialSSH(ctx, addr, password)
 			if err == nil {
 				logFn("Reconnected to device using stored credentials", "")
 				return client, password, nil
@@ -245,13 +463,15 @@ func reconnectAfterFirmware(params *Parameters, logFn func(string, string)) (*ss
 			}
 		}
 
-		client, pwd, err := InitSshClient(params.Ip, params.PromptPassword)
+		client, pwd, err := InitSshClient(ctx, params.Ip, params.PromptPassword)
 		if err == nil {
 			logFn("Reconnected to device after reboot", "")
 			return client, pwd, nil
 		}
 
-		time.Sleep(firmwareReconnectInterval)
+		if err := sleepWithContext(ctx, firmwareReconnectInterval); err != nil {
+			return nil, password, err
+		}
 	}
 
 	return nil, password, errors.New("timed out waiting for device to reboot")
@@ -0,0 +1,75 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// verifyImageDigest logs the digest of the image a container was created from and, if a digest is
+// pinned, fails when the image on the device does not match it. A pinned digest matches either one of
+// the image's repository digests or, for images loaded offline, the image ID.
+func verifyImageDigest(client *ssh.Client, logFn func(string, string), definition ContainerDefinition) error {
+	repoDigests, imageID, err := inspectImageDigests(client, definition.Image)
+	if err != nil {
+		return err
+	}
+
+	recorded := imageID
+	if len(repoDigests) > 0 {
+		recorded = repoDigests[0]
+	}
+	logFn("Image digest: "+recorded, "")
+
+	pinned := strings.TrimSpace(definition.PinnedDigest)
+	if pinned == "" {
+		return nil
+	}
+	if !strings.Contains(pinned, ":") {
+		pinned = "sha256:" + pinned
+	}
+
+	for _, repoDigest := range repoDigests {
+		if digestPart(repoDigest) == pinned {
+			logFn("Image digest matches pinned digest", "")
+			return nil
+		}
+	}
+	if imageID == pinned {
+		logFn("Image ID matches pinned digest", "")
+		return nil
+	}
+
+	return fmt.Errorf("image digest mismatch: expected %s, device has %s", pinned, recorded)
+}
+
+func inspectImageDigests(client *ssh.Client, image string) ([]string, string, error) {
+	cmd := fmt.Sprintf("docker image inspect -f %s %s",
+		shellQuote(`{{.Id}}|{{join .RepoDigests ","}}`), shellQuote(image))
+	output, err := runSSHCommand(client, cmd, shortSessionTimeout)
+	if err != nil {
+		return nil, "", fmt.Errorf("inspect image digest: %w", err)
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(output), "|", 2)
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("unexpected image inspect output: %s", output)
+	}
+
+	var repoDigests []string
+	for _, digest := range strings.Split(parts[1], ",") {
+		if trimmed := strings.TrimSpace(digest); trimmed != "" {
+			repoDigests = append(repoDigests, trimmed)
+		}
+	}
+	return repoDigests, strings.TrimSpace(parts[0]), nil
+}
+
+// digestPart strips the repository from a "repo@sha256:..." reference.
+func digestPart(repoDigest string) string {
+	if idx := strings.LastIndex(repoDigest, "@"); idx >= 0 {
+		return repoDigest[idx+1:]
+	}
+	return repoDigest
+}
@@ -0,0 +1,282 @@
+package install
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"wago-init/internal/registry"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const imageLoadTimeout = 45 * time.Minute
+
+// Image sources selectable in the container settings.
+const (
+	ImageSourceRegistry = "registry"
+	ImageSourceTarball  = "tarball"
+	ImageSourceStation  = "station"
+)
+
+// offlineImageSource reports whether the images of source are loaded onto the device by the station, so
+// docker on the device must not fall back to pulling from a registry.
+func offlineImageSource(source string) bool {
+	return source != "" && source != ImageSourceRegistry
+}
+
+// LoadImagesOffline transfers the container images to the device without the device contacting a
+// registry. Depending on params.ImageSource it streams a local "docker save" tarball or pulls and saves
+// every configured image on the station first.
+func LoadImagesOffline(client *ssh.Client, logFn func(string, string), params Parameters) error {
+	switch params.ImageSource {
+	case ImageSourceTarball:
+		return withTransferSlot(&params, "image load", logFn, func() error {
+			return loadImageTarball(params.Context, client, params.ImageTarball, logFn)
+		})
+	case ImageSourceStation:
+		images := uniqueImages(params.Containers)
+		if len(images) == 0 {
+			return errors.New("no container images configured for station pull")
+		}
+		platform, err := devicePlatform(params.Context, client)
+		if err != nil {
+			return err
+		}
+		logFn("Device platform: "+platform, "")
+		if err := stationRegistryLogin(params.Context, params.Registry, logFn); err != nil {
+			return err
+		}
+		for _, image := range images {
+			err := withTransferSlot(&params, "image load", logFn, func() error {
+				return loadStationImage(params.Context, client, image, platform, logFn)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported offline image source '%s'", params.ImageSource)
+	}
+}
+
+func loadImageTarball(ctx context.Context, client *ssh.Client, tarballPath string, logFn func(string, string)) error {
+	tarballPath = strings.TrimSpace(tarballPath)
+	if tarballPath == "" {
+		return errors.New("image tarball is not configured")
+	}
+
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return fmt.Errorf("open image tarball: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat image tarball: %w", err)
+	}
+
+	logFn(fmt.Sprintf("Loading image tarball %s (%s) into device", filepath.Base(tarballPath), formatBytes(info.Size())), "")
+	return streamToDockerLoad(ctx, client, file, info.Size(), "Loading "+filepath.Base(tarballPath), logFn)
+}
+
+// loadStationImage pulls image for the device platform with the station's docker and pipes "docker save"
+// straight into "docker load" on the device, without an intermediate file.
+func loadStationImage(ctx context.Context, client *ssh.Client, image, platform string, logFn func(string, string)) error {
+	ctx = contextOrBackground(ctx)
+
+	logFn("Pulling "+image+" for "+platform+" on station", "")
+	pullCmd := exec.CommandContext(ctx, "docker", "pull", "--platform", platform, image)
+	hideConsoleWindow(pullCmd)
+	if out, err := pullCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("station docker pull %s: %w (output: %s)", image, err, strings.TrimSpace(string(out)))
+	}
+
+	inspectCmd := exec.CommandContext(ctx, "docker", "image", "inspect", "-f", "{{.Os}}/{{.Architecture}}{{if .Variant}}/{{.Variant}}{{end}}", image)
+	hideConsoleWindow(inspectCmd)
+	out, err := inspectCmd.Output()
+	if err != nil {
+		return fmt.Errorf("station docker image inspect %s: %w", image, err)
+	}
+	if imagePlatform := strings.TrimSpace(string(out)); !platformMatches(imagePlatform, platform) {
+		return fmt.Errorf("station pulled %s for %s, but the device needs %s", image, imagePlatform, platform)
+	}
+
+	var size int64
+	sizeCmd := exec.CommandContext(ctx, "docker", "image", "inspect", "-f", "{{.Size}}", image)
+	hideConsoleWindow(sizeCmd)
+	if out, err := sizeCmd.Output(); err == nil {
+		fmt.Sscanf(strings.TrimSpace(string(out)), "%d", &size)
+	}
+
+	saveCmd := exec.CommandContext(ctx, "docker", "save", image)
+	hideConsoleWindow(saveCmd)
+	stdout, err := saveCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("station docker save: %w", err)
+	}
+	var stderr strings.Builder
+	saveCmd.Stderr = &stderr
+	if err := saveCmd.Start(); err != nil {
+		return fmt.Errorf("start station docker save: %w", err)
+	}
+
+	logFn("Transferring "+image+" to device", "")
+	loadErr := streamToDockerLoad(ctx, client, stdout, size, "Loading "+image, logFn)
+	if loadErr != nil {
+		_ = saveCmd.Process.Kill()
+	}
+	saveErr := saveCmd.Wait()
+
+	if loadErr != nil {
+		return loadErr
+	}
+	if saveErr != nil {
+		return fmt.Errorf("station docker save %s: %w (stderr: %s)", image, saveErr, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// devicePlatform returns the docker platform of the device, such as linux/arm/v7, from its machine type.
+func devicePlatform(ctx context.Context, client *ssh.Client) (string, error) {
+	machine, err := runSSHCommandContext(ctx, client, "uname -m", shortSessionTimeout)
+	if err != nil {
+		return "", fmt.Errorf("read device architecture: %w", err)
+	}
+	platforms := map[string]string{
+		"armv7l":  "linux/arm/v7",
+		"armv6l":  "linux/arm/v6",
+		"aarch64": "linux/arm64",
+		"arm64":   "linux/arm64",
+		"x86_64":  "linux/amd64",
+		"i686":    "linux/386",
+	}
+	platform, ok := platforms[strings.TrimSpace(machine)]
+	if !ok {
+		return "", fmt.Errorf("unsupported device architecture '%s'", strings.TrimSpace(machine))
+	}
+	return platform, nil
+}
+
+// platformMatches compares an image platform with the wanted one. The variant is only compared when
+// both name one, as single-platform images often omit it.
+func platformMatches(image, wanted string) bool {
+	imageParts := strings.Split(image, "/")
+	wantedParts := strings.Split(wanted, "/")
+	if len(imageParts) < 2 || len(wantedParts) < 2 {
+		return false
+	}
+	if imageParts[0] != wantedParts[0] || imageParts[1] != wantedParts[1] {
+		return false
+	}
+	return len(imageParts) < 3 || len(wantedParts) < 3 || imageParts[2] == wantedParts[2]
+}
+
+// streamToDockerLoad pipes r into "docker load" on the device and reports byte-level progress.
+// The image size of a station save is only an estimate, so percentages may stop short of 100.
+func streamToDockerLoad(ctx context.Context, client *ssh.Client, r io.Reader, total int64, label string, logFn func(string, string)) error {
+	ctx = contextOrBackground(ctx)
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+	defer sess.Close()
+
+	progress := newProgressReader(r, label, total, logFn)
+	sess.Stdin = progress
+
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("stdout pipe: %w", err)
+	}
+	var stderr strings.Builder
+	sess.Stderr = &stderr
+
+	if err := sess.Start("docker load"); err != nil {
+		return fmt.Errorf("start docker load: %w", err)
+	}
+
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				logFn(line, "")
+			}
+		}
+	}()
+
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- sess.Wait()
+	}()
+
+	var runErr error
+	select {
+	case runErr = <-waitCh:
+	case <-time.After(imageLoadTimeout):
+		runErr = fmt.Errorf("docker load timed out after %s", imageLoadTimeout)
+		_ = sess.Signal(ssh.SIGKILL)
+		_ = sess.Close()
+	case <-ctx.Done():
+		runErr = ctx.Err()
+		_ = sess.Signal(ssh.SIGKILL)
+		_ = sess.Close()
+	}
+	<-outputDone
+
+	if runErr != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("docker load: %w (stderr: %s)", runErr, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("docker load: %w", runErr)
+	}
+
+	progress.Finish()
+	return nil
+}
+
+// stationRegistryLogin authenticates the station's docker against the registry so private images can be pulled.
+func stationRegistryLogin(ctx context.Context, creds registry.Credentials, logFn func(string, string)) error {
+	if creds.Anonymous() {
+		return nil
+	}
+	args := []string{"login", "--username", creds.Username, "--password-stdin"}
+	if creds.Server != "" {
+		args = append(args, creds.Server)
+	}
+	cmd := exec.CommandContext(contextOrBackground(ctx), "docker", args...)
+	hideConsoleWindow(cmd)
+	cmd.Stdin = strings.NewReader(creds.Password)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("station registry login: %w (output: %s)", err, strings.TrimSpace(string(out)))
+	}
+	logFn("Station logged in to registry", "")
+	return nil
+}
+
+func uniqueImages(containers []ContainerDefinition) []string {
+	seen := make(map[string]struct{}, len(containers))
+	var images []string
+	for _, definition := range sortedContainers(containers) {
+		if definition.Image == "" {
+			continue
+		}
+		if _, exists := seen[definition.Image]; exists {
+			continue
+		}
+		seen[definition.Image] = struct{}{}
+		images = append(images, definition.Image)
+	}
+	return images
+}
@@ -14,7 +14,7 @@ var allowedOUIs = []string{
 	"00:30:de",
 }
 
-func CheckMacAddress(installParameters Parameters, logFn func(string, string)) error {
+func CheckMacAddress(installParameters Parameters, logFn func(string, string)) (string, error) {
 	ip := installParameters.Ip
 
 	if err := PingOnce(ip); err != nil {
@@ -23,14 +23,14 @@ func CheckMacAddress(installParameters Parameters, logFn func(string, string)) e
 
 	mac, allowed, err := DiscoverDeviceMAC(ip)
 	if err != nil {
-		return fmt.Errorf("failed to resolve MAC for %s: %w", ip, err)
+		return "", fmt.Errorf("failed to resolve MAC for %s: %w", ip, err)
 	}
 	if !allowed {
-		return errors.New("this device is not supported")
+		return "", errors.New("this device is not supported")
 	}
 
 	logFn("Device MAC address: "+mac, "")
-	return nil
+	return mac, nil
 }
 
 func DiscoverDeviceMAC(ip string) (string, bool, error) {
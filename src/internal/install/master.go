@@ -17,7 +17,7 @@ func Install(installParameters Parameters, logFn func(string, string), progressF
 	}
 	logFn("Starting process for IP: "+params.Ip, "")
 
-	err = CheckMacAddress(params, logFn)
+	params.Device.MAC, err = CheckMacAddress(params, logFn)
 	if err != nil {
 		return err
 	}
@@ -26,7 +26,7 @@ func Install(installParameters Parameters, logFn func(string, string), progressF
 	}
 
 	ip := params.Ip
-	client, password, err := InitSshClient(ip, params.PromptPassword)
+	client, password, err := InitSshClient(params.Context, ip, params.PromptPassword)
 	if err != nil {
 		return err
 	}
@@ -38,7 +38,7 @@ func Install(installParameters Parameters, logFn func(string, string), progressF
 		return err
 	}
 
-	err = CheckSerialNumber(client, logFn)
+	params.Device.Serial, err = CheckSerialNumber(client, logFn)
 	if err != nil {
 		return err
 	}
@@ -78,14 +78,14 @@ func Install(installParameters Parameters, logFn func(string, string), progressF
 		return err
 	}
 
-	err = ChangeUserPasswords(client, logFn, newPassword)
+	err = ChangeUserPasswords(params.Context, client, logFn, newPassword)
 	if err != nil {
 		return err
 	}
 
 	progressFn(0.6, 0.64)
 
-	err = ConfigureServices(client, logFn)
+	err = ConfigureServices(params.Context, client, logFn, params.DockerCleanup, params.ConfirmDockerCleanup)
 	if err != nil {
 		return err
 	}
@@ -94,7 +94,12 @@ func Install(installParameters Parameters, logFn func(string, string), progressF
 	}
 	progressFn(0.65, 0.99)
 
-	err = CreateContainer(client, logFn, params)
+	err = resolveDeviceInfo(params.Context, client, &params)
+	if err != nil {
+		return err
+	}
+
+	err = CreateContainer(client, logFn, params, progressFn)
 	if err != nil {
 		return err
 	}
@@ -102,7 +107,19 @@ func Install(installParameters Parameters, logFn func(string, string), progressF
 		return err
 	}
 
-	if err := CopyPathToDevice(client, params.Context, params.ConfigPath, "/root", logFn); err != nil {
+	copyOptions := CopyOptions{
+		Templates:        params.ConfigTemplates,
+		TemplateData:     newTemplateData(params.Device, params.Variables, params.TemplateProfile),
+		Sync:             params.CopySync,
+		SyncDelete:       params.CopySyncDelete,
+		DryRun:           params.CopyDryRun,
+		Excludes:         params.CopyExcludes,
+		Compress:         params.CopyCompress,
+		CompressionLevel: params.CopyCompressionLevel,
+		Progress:         progressRange(progressFn, 0.95, 0.99),
+	}
+	err = copyMappingsToDevice(client, &params, copyOptions, logFn)
+	if err != nil {
 		return err
 	}
 
@@ -1,6 +1,7 @@
 package install
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 
@@ -8,14 +9,14 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
-func ChangeUserPasswords(client *ssh.Client, logFn func(string, string), newPassword string) error {
+func ChangeUserPasswords(ctx context.Context, client *ssh.Client, logFn func(string, string), newPassword string) error {
 
 	hash, err := hashPasswordSHA512(newPassword)
 	if err != nil {
 		return err
 	}
 	for _, user := range usersList {
-		_, err := runSSHCommand(client, "usermod -p '"+hash+"' "+user, shortSessionTimeout)
+		_, err := runSSHCommandContext(ctx, client, "usermod -p '"+hash+"' "+user, shortSessionTimeout)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,138 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const progressReportInterval = time.Second
+
+// progressMeter counts transferred bytes and periodically reports percent, throughput and ETA as a
+// replaceable log line. A total of zero or less reports transferred bytes and throughput only.
+// onFraction, if set, additionally receives the completed fraction whenever a report is made.
+type progressMeter struct {
+	label      string
+	total      int64
+	logFn      func(string, string)
+	onFraction func(float64)
+	mu         sync.Mutex
+	read       int64
+	started    time.Time
+	lastReport time.Time
+}
+
+// progressReader reports the bytes read through it.
+type progressReader struct {
+	r io.Reader
+	*progressMeter
+}
+
+// progressWriter reports the bytes written through it.
+type progressWriter struct {
+	w io.Writer
+	*progressMeter
+}
+
+func newProgressMeter(label string, total int64, logFn func(string, string)) *progressMeter {
+	now := time.Now()
+	return &progressMeter{
+		label:      label,
+		total:      total,
+		logFn:      logFn,
+		started:    now,
+		lastReport: now,
+	}
+}
+
+func newProgressReader(r io.Reader, label string, total int64, logFn func(string, string)) *progressReader {
+	return &progressReader{r: r, progressMeter: newProgressMeter(label, total, logFn)}
+}
+
+func newProgressWriter(w io.Writer, label string, total int64, logFn func(string, string)) *progressWriter {
+	return &progressWriter{w: w, progressMeter: newProgressMeter(label, total, logFn)}
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.add(n)
+	return n, err
+}
+
+func (p *progressWriter) Write(buf []byte) (int, error) {
+	n, err := p.w.Write(buf)
+	p.add(n)
+	return n, err
+}
+
+func (p *progressMeter) add(n int) {
+	if n <= 0 {
+		return
+	}
+	p.mu.Lock()
+	p.read += int64(n)
+	report := time.Since(p.lastReport) >= progressReportInterval
+	if report {
+		p.lastReport = time.Now()
+	}
+	p.mu.Unlock()
+	if report {
+		p.report()
+	}
+}
+
+// Finish logs the final transfer summary.
+func (p *progressMeter) Finish() {
+	p.report()
+}
+
+func (p *progressMeter) report() {
+	p.mu.Lock()
+	read, total, elapsed := p.read, p.total, time.Since(p.started)
+	p.mu.Unlock()
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(read) / elapsed.Seconds()
+	}
+
+	prefix := p.label + ": "
+	if total > 0 {
+		fraction := float64(read) / float64(total)
+		if fraction > 1 {
+			fraction = 1
+		}
+		eta := "--"
+		if rate > 0 && read < total {
+			eta = time.Duration(float64(total-read) / rate * float64(time.Second)).Round(time.Second).String()
+		}
+		p.logFn(fmt.Sprintf("%s%.0f%% (%s / %s, %s/s, ETA %s)", prefix, fraction*100, formatBytes(read), formatBytes(total), formatBytes(int64(rate)), eta), prefix)
+		if p.onFraction != nil {
+			p.onFraction(fraction)
+		}
+		return
+	}
+	p.logFn(fmt.Sprintf("%s%s (%s/s)", prefix, formatBytes(read), formatBytes(int64(rate))), prefix)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for value := n / unit; value >= unit; value /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// progressRange maps a completed fraction onto the session progress range [start, end].
+func progressRange(progressFn func(float64, float64), start, end float64) func(float64) {
+	return func(fraction float64) {
+		value := start + (end-start)*fraction
+		progressFn(value, value)
+	}
+}
@@ -0,0 +1,162 @@
+package install
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Share of a layer's progress attributed to downloading; the rest is extraction.
+const pullDownloadWeight = 0.7
+
+var (
+	pullLayerPattern = regexp.MustCompile(`^([0-9a-f]{12}): (.+)$`)
+	pullBytesPattern = regexp.MustCompile(`([0-9.]+)\s*([kKMGT]?B)/([0-9.]+)\s*([kKMGT]?B)\s*$`)
+)
+
+type pullLayer struct {
+	id       string
+	status   string
+	fraction float64
+}
+
+// pullProgress parses the output of a docker pull (also printed by docker create for missing images).
+// It keeps one replaceable log line per layer plus a summary line and maps the aggregated layer progress
+// onto the session progress range [start, end]. Every pull gets its own instance.
+type pullProgress struct {
+	logFn       func(string, string)
+	progressFn  func(float64, float64)
+	start, end  float64
+	layers      map[string]*pullLayer
+	order       []string
+	lastPercent int
+}
+
+func newPullProgress(logFn func(string, string), progressFn func(float64, float64), start, end float64) *pullProgress {
+	return &pullProgress{
+		logFn:       logFn,
+		progressFn:  progressFn,
+		start:       start,
+		end:         end,
+		layers:      make(map[string]*pullLayer),
+		lastPercent: -1,
+	}
+}
+
+// Handle consumes one output line. Lines that are not layer updates are logged unchanged.
+func (p *pullProgress) Handle(line, _ string) {
+	m := pullLayerPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		p.logFn(line, "")
+		return
+	}
+
+	id, status := m[1], strings.TrimSpace(m[2])
+	layer, ok := p.layers[id]
+	if !ok {
+		layer = &pullLayer{id: id}
+		p.layers[id] = layer
+		p.order = append(p.order, id)
+	}
+
+	fraction, known := layerFraction(status)
+	if known && fraction > layer.fraction {
+		layer.fraction = fraction
+	}
+	layer.status = compactLayerStatus(status)
+
+	p.logFn(fmt.Sprintf("Layer %s: %s", id, layer.status), "Layer "+id)
+	p.report()
+}
+
+func (p *pullProgress) report() {
+	if len(p.order) == 0 {
+		return
+	}
+
+	var sum float64
+	complete := 0
+	for _, id := range p.order {
+		layer := p.layers[id]
+		sum += layer.fraction
+		if layer.fraction >= 1 {
+			complete++
+		}
+	}
+	overall := sum / float64(len(p.order))
+	percent := int(overall * 100)
+	if percent == p.lastPercent {
+		return
+	}
+	p.lastPercent = percent
+
+	p.logFn(fmt.Sprintf("Pulling image: %d%% (%d/%d layers complete)", percent, complete, len(p.order)), "Pulling image")
+	if p.progressFn != nil {
+		value := p.start + (p.end-p.start)*overall
+		p.progressFn(value, value)
+	}
+}
+
+// layerFraction maps a docker layer status to the completed fraction of that layer. The second result
+// is false for statuses that carry no progress information.
+func layerFraction(status string) (float64, bool) {
+	switch {
+	case status == "Pulling fs layer", status == "Waiting":
+		return 0, true
+	case strings.HasPrefix(status, "Downloading"):
+		if current, total, ok := parseLayerBytes(status); ok {
+			return pullDownloadWeight * current / total, true
+		}
+		return 0, false
+	case status == "Verifying Checksum", status == "Download complete":
+		return pullDownloadWeight, true
+	case strings.HasPrefix(status, "Extracting"):
+		if current, total, ok := parseLayerBytes(status); ok {
+			return pullDownloadWeight + (1-pullDownloadWeight)*current/total, true
+		}
+		return pullDownloadWeight, true
+	case status == "Pull complete", status == "Already exists":
+		return 1, true
+	}
+	return 0, false
+}
+
+// compactLayerStatus drops the ASCII progress bar docker prints when attached to a terminal.
+func compactLayerStatus(status string) string {
+	if open := strings.Index(status, "["); open >= 0 {
+		if closing := strings.Index(status[open:], "]"); closing >= 0 {
+			status = status[:open] + strings.TrimSpace(status[open+closing+1:])
+		}
+	}
+	return strings.Join(strings.Fields(status), " ")
+}
+
+func parseLayerBytes(status string) (float64, float64, bool) {
+	m := pullBytesPattern.FindStringSubmatch(status)
+	if m == nil {
+		return 0, 0, false
+	}
+	current, err1 := parseByteSize(m[1], m[2])
+	total, err2 := parseByteSize(m[3], m[4])
+	if err1 != nil || err2 != nil || total <= 0 {
+		return 0, 0, false
+	}
+	if current > total {
+		current = total
+	}
+	return current, total, true
+}
+
+func parseByteSize(number, unit string) (float64, error) {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	multipliers := map[string]float64{"B": 1, "kB": 1e3, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12}
+	multiplier, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", unit)
+	}
+	return value * multiplier, nil
+}
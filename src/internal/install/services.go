@@ -1,29 +1,28 @@
 package install
 
-import "golang.org/x/crypto/ssh"
+import (
+	"context"
+
+	"golang.org/x/crypto/ssh"
+)
 
 var (
-	NtpCommand             = "/etc/config-tools/config_sntp state=enabled time-server-1=pool.ntp.org update-time=600"
-	DockerCommand          = "/etc/config-tools/config_docker activate"
-	DockerRemoveContainers = "docker rm -vf $(docker ps -aq)"
-	DockerRemoveImages     = "docker rmi -f $(docker images -aq)"
+	NtpCommand    = "/etc/config-tools/config_sntp state=enabled time-server-1=pool.ntp.org update-time=600"
+	DockerCommand = "/etc/config-tools/config_docker activate"
 )
 
-func ConfigureServices(client *ssh.Client, logFn func(string, string)) error {
-	ntpOut, err := runSSHCommand(client, NtpCommand, shortSessionTimeout)
+func ConfigureServices(ctx context.Context, client *ssh.Client, logFn func(string, string), cleanupPolicy string, confirmCleanup func(string) bool) error {
+	ntpOut, err := runSSHCommandContext(ctx, client, NtpCommand, shortSessionTimeout)
 	if err != nil {
 		return err
 	}
 	logFn("NTP set to pool.ntp.org "+ntpOut, "")
 
-	dockerOut, err := runSSHCommand(client, DockerCommand, longSessionTimeout)
+	dockerOut, err := runSSHCommandContext(ctx, client, DockerCommand, longSessionTimeout)
 	if err != nil {
 		return err
 	}
 	logFn("Docker Service activated "+dockerOut, "")
 
-	runSSHCommand(client, DockerRemoveContainers, longSessionTimeout)
-	runSSHCommand(client, DockerRemoveImages, longSessionTimeout)
-
-	return nil
+	return CleanupDocker(ctx, client, logFn, cleanupPolicy, confirmCleanup)
 }
@@ -3,6 +3,7 @@ package install
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -21,12 +22,14 @@ var (
 	longSessionTimeout  = 90 * time.Second
 )
 
-func InitSshClient(ip string, promptPassword func() (string, bool)) (*ssh.Client, string, error) {
+// InitSshClient connects to the device, asking promptPassword for another password while the current one
+// is rejected. Cancelling ctx aborts a pending connection attempt.
+func InitSshClient(ctx context.Context, ip string, promptPassword func() (string, bool)) (*ssh.Client, string, error) {
 	addr := net.JoinHostPort(ip, "22")
 	password := DefaultSSHPassword
 
 	for {
-		client, err := dialSSH(addr, password)
+		client, err := dialSSH(ctx, addr, password)
 		if err == nil {
 			return client, password, nil
 		}
@@ -47,7 +50,9 @@ func InitSshClient(ip string, promptPassword func() (string, bool)) (*ssh.Client
 	}
 }
 
-func dialSSH(addr, password string) (*ssh.Client, error) {
+// dialSSH behaves like ssh.Dial but gives up as soon as ctx is done, including during the handshake.
+func dialSSH(ctx context.Context, addr, password string) (*ssh.Client, error) {
+	ctx = contextOrBackground(ctx)
 	config := &ssh.ClientConfig{
 		User:            DefaultSSHUser,
 		Auth:            []ssh.AuthMethod{ssh.Password(password)},
@@ -55,7 +60,25 @@ func dialSSH(addr, password string) (*ssh.Client, error) {
 		Timeout:         sshTimeout,
 	}
 
-	return ssh.Dial("tcp", addr, config)
+	dialer := net.Dialer{Timeout: sshTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake does not take a context; closing the connection is what aborts it.
+	stopClose := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stopClose() {
+		if err == nil {
+			_ = clientConn.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
 }
 
 func isAuthError(err error) bool {
@@ -68,6 +91,21 @@ func isAuthError(err error) bool {
 }
 
 func runSSHCommand(client *ssh.Client, cmd string, timeout time.Duration) (string, error) {
+	return runSSHCommandContext(context.Background(), client, cmd, timeout)
+}
+
+// runSSHCommandContext behaves like runSSHCommand but also aborts the remote command when ctx is done.
+func runSSHCommandContext(ctx context.Context, client *ssh.Client, cmd string, timeout time.Duration) (string, error) {
+	return runSSHCommandInputContext(ctx, client, cmd, nil, timeout)
+}
+
+// runSSHCommandInputContext behaves like runSSHCommandContext and feeds stdin to the remote command.
+func runSSHCommandInputContext(ctx context.Context, client *ssh.Client, cmd string, stdin io.Reader, timeout time.Duration) (string, error) {
+	ctx = contextOrBackground(ctx)
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	sess, err := client.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("create session: %w", err)
@@ -75,6 +113,7 @@ func runSSHCommand(client *ssh.Client, cmd string, timeout time.Duration) (strin
 	defer sess.Close()
 
 	var outBuf, errBuf bytes.Buffer
+	sess.Stdin = stdin
 	sess.Stdout = &outBuf
 	sess.Stderr = &errBuf
 
@@ -92,10 +131,19 @@ func runSSHCommand(client *ssh.Client, cmd string, timeout time.Duration) (strin
 	case <-time.After(timeout):
 		_ = sess.Signal(ssh.SIGKILL)
 		return "", fmt.Errorf("command '%s' timed out", cmd)
+	case <-ctx.Done():
+		_ = sess.Signal(ssh.SIGKILL)
+		return "", ctx.Err()
 	}
 }
 
 func runSSHCommandStreaming(client *ssh.Client, cmd string, timeout time.Duration, logFn func(string, string)) error {
+	return runSSHCommandStreamingContext(context.Background(), client, cmd, timeout, logFn)
+}
+
+// runSSHCommandStreamingContext behaves like runSSHCommandStreaming but also aborts the remote command when ctx is done.
+func runSSHCommandStreamingContext(ctx context.Context, client *ssh.Client, cmd string, timeout time.Duration, logFn func(string, string)) error {
+	ctx = contextOrBackground(ctx)
 	if client == nil {
 		return fmt.Errorf("ssh client is nil")
 	}
@@ -173,6 +221,14 @@ func runSSHCommandStreaming(client *ssh.Client, cmd string, timeout time.Duratio
 		case <-done:
 		case <-time.After(5 * time.Second):
 		}
+	case <-ctx.Done():
+		runErr = ctx.Err()
+		_ = sess.Signal(ssh.SIGKILL)
+		_ = sess.Close()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
 	}
 
 	wg.Wait()
@@ -15,19 +15,19 @@ var (
 	FirmwareCommand = "/etc/config-tools/get_coupler_details firmware-revision"
 )
 
-func CheckSerialNumber(client *ssh.Client, logFn func(string, string)) error {
+func CheckSerialNumber(client *ssh.Client, logFn func(string, string)) (string, error) {
 
 	serialOut, err := runSSHCommand(client, SerialCommand, shortSessionTimeout)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	serial := parseSerial(serialOut)
 	if serial == "" {
-		return errors.New("serial output empty after parsing")
+		return "", errors.New("serial output empty after parsing")
 	}
 	logFn("Device serial number: "+serial, "")
-	return nil
+	return serial, nil
 }
 
 func CheckFirmware(client *ssh.Client, logFn func(string, string), newestFirmware int) (bool, error) {
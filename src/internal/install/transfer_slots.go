@@ -0,0 +1,106 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const (
+	DefaultTransferSlots    = 2
+	transferWaitIdentifier  = "Waiting for transfer slot"
+	transferSlotAcquiredMsg = "Transfer slot acquired"
+)
+
+// TransferScheduler limits how many heavy transfers (firmware uploads, image loads, config copies) run at
+// the same time across all sessions of the station. A limit of zero or less disables the limit.
+type TransferScheduler struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	changed chan struct{}
+}
+
+func NewTransferScheduler(limit int) *TransferScheduler {
+	return &TransferScheduler{
+		limit:   limit,
+		changed: make(chan struct{}),
+	}
+}
+
+// SetLimit changes the number of slots. Waiting transfers are re-evaluated immediately.
+func (t *TransferScheduler) SetLimit(limit int) {
+	t.mu.Lock()
+	t.limit = limit
+	t.notifyLocked()
+	t.mu.Unlock()
+}
+
+// Acquire blocks until a transfer slot is free or ctx is done. The returned release function must be
+// called once the transfer has finished. While waiting, a replaceable status line is logged. onWait, if
+// set, is called with true when Acquire starts to wait and with false when the wait ends.
+func (t *TransferScheduler) Acquire(ctx context.Context, what string, logFn func(string, string), onWait func(waiting bool)) (func(), error) {
+	if t == nil {
+		return func() {}, nil
+	}
+	ctx = contextOrBackground(ctx)
+
+	waited := false
+	for {
+		t.mu.Lock()
+		if t.limit <= 0 || t.active < t.limit {
+			t.active++
+			t.mu.Unlock()
+			if waited {
+				logFn(fmt.Sprintf("%s for %s", transferSlotAcquiredMsg, what), transferWaitIdentifier)
+				if onWait != nil {
+					onWait(false)
+				}
+			}
+			var once sync.Once
+			return func() { once.Do(t.release) }, nil
+		}
+		changed := t.changed
+		active, limit := t.active, t.limit
+		t.mu.Unlock()
+
+		if !waited {
+			logFn(fmt.Sprintf("%s (%d/%d in use) for %s", transferWaitIdentifier, active, limit, what), transferWaitIdentifier)
+			waited = true
+			if onWait != nil {
+				onWait(true)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if onWait != nil {
+				onWait(false)
+			}
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (t *TransferScheduler) release() {
+	t.mu.Lock()
+	t.active--
+	t.notifyLocked()
+	t.mu.Unlock()
+}
+
+func (t *TransferScheduler) notifyLocked() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// withTransferSlot runs fn while holding a slot of the session's transfer scheduler.
+func withTransferSlot(params *Parameters, what string, logFn func(string, string), fn func() error) error {
+	release, err := params.TransferScheduler.Acquire(params.Context, what, logFn, params.TransferWaiting)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
@@ -0,0 +1,258 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"wago-init/internal/fs"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Built-in device variables that can be used as ${NAME} in container flags and image references.
+const (
+	VariableSerial        = "SERIAL"
+	VariableMAC           = "MAC"
+	VariableIP            = "IP"
+	VariableHostname      = "HOSTNAME"
+	VariableFirmwareBuild = "FW_BUILD"
+)
+
+var (
+	builtinVariables    = []string{VariableSerial, VariableMAC, VariableIP, VariableHostname, VariableFirmwareBuild}
+	variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	placeholderPattern  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// DeviceInfo holds what the session discovered about the device. It provides the built-in variables.
+type DeviceInfo struct {
+	Serial        string
+	MAC           string
+	IP            string
+	Hostname      string
+	FirmwareBuild string
+}
+
+// VariableDefinition is a custom variable. Definitions without Device apply to every device; the others
+// only to the device whose serial number or MAC address equals Device and take precedence.
+type VariableDefinition struct {
+	Device string
+	Name   string
+	Value  string
+}
+
+// ParseVariableDefinitions parses the stored custom variables. Each line is either NAME=value or
+// <serial|MAC> NAME=value. Empty lines and lines starting with '#' are ignored.
+func ParseVariableDefinitions(raw string) ([]VariableDefinition, error) {
+	decoded := strings.ReplaceAll(fs.DecodeMultilineValue(raw), "\r\n", "\n")
+
+	var definitions []VariableDefinition
+	for i, line := range strings.Split(decoded, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		var definition VariableDefinition
+		assignment := trimmed
+		if device, rest, found := strings.Cut(trimmed, " "); found && !strings.Contains(device, "=") {
+			definition.Device = device
+			assignment = strings.TrimSpace(rest)
+		}
+
+		name, value, found := strings.Cut(assignment, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected NAME=value or <serial|MAC> NAME=value", i+1)
+		}
+		definition.Name = strings.TrimSpace(name)
+		definition.Value = strings.TrimSpace(value)
+		if !variableNamePattern.MatchString(definition.Name) {
+			return nil, fmt.Errorf("line %d: invalid variable name '%s'", i+1, definition.Name)
+		}
+		if isBuiltinVariable(definition.Name) {
+			return nil, fmt.Errorf("line %d: %s is a built-in variable and cannot be overridden", i+1, definition.Name)
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, nil
+}
+
+// Variables returns the built-in variables of the device merged with the custom definitions that apply to it.
+func (d DeviceInfo) Variables(custom []VariableDefinition) map[string]string {
+	vars := map[string]string{
+		VariableSerial:        d.Serial,
+		VariableMAC:           d.MAC,
+		VariableIP:            d.IP,
+		VariableHostname:      d.Hostname,
+		VariableFirmwareBuild: d.FirmwareBuild,
+	}
+	for _, definition := range custom {
+		if definition.Device == "" {
+			vars[definition.Name] = definition.Value
+		}
+	}
+	for _, definition := range custom {
+		if definition.Device != "" && d.matches(definition.Device) {
+			vars[definition.Name] = definition.Value
+		}
+	}
+	return vars
+}
+
+func (d DeviceInfo) matches(device string) bool {
+	return (d.Serial != "" && strings.EqualFold(device, d.Serial)) ||
+		(d.MAC != "" && strings.EqualFold(device, d.MAC))
+}
+
+// SubstituteVariables replaces every ${NAME} with its value. Unknown names are left for the device shell,
+// which is logged so typos do not go unnoticed.
+func SubstituteVariables(text string, vars map[string]string, logFn func(string, string)) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-1]
+		value, ok := vars[name]
+		if !ok {
+			logFn(fmt.Sprintf("Warning: unknown variable %s is passed to the device shell unchanged", placeholder), "")
+			return placeholder
+		}
+		return value
+	})
+}
+
+// resolveDeviceInfo completes the device info with values that can change during the session,
+// such as the firmware build after an update.
+func resolveDeviceInfo(ctx context.Context, client *ssh.Client, params *Parameters) error {
+	params.Device.IP = params.Ip
+
+	hostname, err := runSSHCommandContext(ctx, client, "hostname", shortSessionTimeout)
+	if err != nil {
+		return fmt.Errorf("read hostname: %w", err)
+	}
+	params.Device.Hostname = strings.TrimSpace(hostname)
+
+	fwOut, err := runSSHCommandContext(ctx, client, FirmwareCommand, shortSessionTimeout)
+	if err != nil {
+		return fmt.Errorf("read firmware revision: %w", err)
+	}
+	if _, build := parseFirmwareBuild(fwOut); build != 0 {
+		params.Device.FirmwareBuild = strconv.Itoa(build)
+	}
+	return nil
+}
+
+// applyDeviceVariables substitutes the device variables in the image reference and flags of every container.
+func applyDeviceVariables(definitions []ContainerDefinition, vars map[string]string, logFn func(string, string)) ([]ContainerDefinition, error) {
+	resolved := make([]ContainerDefinition, len(definitions))
+	for i, definition := range definitions {
+		definition.Image = SubstituteVariables(definition.Image, vars, logFn)
+		flags, err := substituteFlagVariables(definition.Flags, vars, logFn)
+		if err != nil {
+			return nil, fmt.Errorf("container %s: %w", containerLabel(definition), err)
+		}
+		definition.Flags = flags
+		resolved[i] = definition
+	}
+	return resolved, nil
+}
+
+// substituteFlagVariables substitutes the device variables in the shell-syntax flags. The flags keep
+// their quoting, so the device shell still expands $VAR where it did before; only the substituted values
+// are quoted for the context they appear in, so each one stays literal. Unknown variables are left to
+// the device shell as written.
+func substituteFlagVariables(flags string, vars map[string]string, logFn func(string, string)) (string, error) {
+	if _, err := splitShellWords(flags); err != nil {
+		return "", err
+	}
+
+	var (
+		out   strings.Builder
+		quote byte
+	)
+	for i := 0; i < len(flags); {
+		c := flags[i]
+		if c == '\\' && quote != '\'' {
+			end := min(i+2, len(flags))
+			out.WriteString(flags[i:end])
+			i = end
+			continue
+		}
+		if c == '$' {
+			if match := placeholderPattern.FindStringSubmatchIndex(flags[i:]); match != nil && match[0] == 0 {
+				name := flags[i+match[2] : i+match[3]]
+				if value, ok := vars[name]; ok {
+					out.WriteString(quoteInContext(value, quote))
+				} else {
+					logFn(fmt.Sprintf("Warning: unknown variable ${%s} is passed to the device shell unchanged", name), "")
+					out.WriteString(flags[i : i+match[1]])
+				}
+				i += match[1]
+				continue
+			}
+		}
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case c == quote:
+			quote = 0
+		}
+		out.WriteByte(c)
+		i++
+	}
+	return out.String(), nil
+}
+
+// quoteInContext quotes value so the device shell takes it literally at a position inside the given
+// quote character, or unquoted for 0.
+func quoteInContext(value string, quote byte) string {
+	switch quote {
+	case '"':
+		var escaped strings.Builder
+		for _, r := range value {
+			if strings.ContainsRune("$`\"\\", r) {
+				escaped.WriteByte('\\')
+			}
+			escaped.WriteRune(r)
+		}
+		return escaped.String()
+	case '\'':
+		return strings.ReplaceAll(value, "'", `'\''`)
+	default:
+		return quoteShellWord(value)
+	}
+}
+
+// logDeviceVariables lists the variables available to the container definitions. Values of variables
+// whose name looks like a credential are masked.
+func logDeviceVariables(vars map[string]string, logFn func(string, string)) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		value := vars[name]
+		if fs.IsSecretKey(name) {
+			value = "********"
+		}
+		parts[i] = name + "=" + value
+	}
+	logFn("Device variables: "+strings.Join(parts, ", "), "")
+}
+
+func isBuiltinVariable(name string) bool {
+	for _, builtin := range builtinVariables {
+		if builtin == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasVariables reports whether text contains ${NAME} placeholders that are resolved per device.
+func hasVariables(text string) bool {
+	return placeholderPattern.MatchString(text)
+}
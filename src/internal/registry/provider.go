@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"wago-init/internal/aws"
+	"wago-init/internal/fs"
+)
+
+// Registry types selectable in the registry settings.
+const (
+	TypeECR       = "ecr"
+	TypeBasic     = "basic"
+	TypeAnonymous = "anonymous"
+)
+
+// Credentials are handed to "docker login" on the device or station. An empty Username means no login is required.
+type Credentials struct {
+	Server   string
+	Username string
+	Password string
+}
+
+// Anonymous reports whether no login is required.
+func (c Credentials) Anonymous() bool {
+	return c.Username == ""
+}
+
+// Provider resolves the credentials for the container registry.
+type Provider interface {
+	Name() string
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// ECR fetches a short-lived token for an AWS Elastic Container Registry.
+type ECR struct {
+	Region    string
+	AccountID string
+	AccessID  string
+	AccessKey string
+}
+
+func (e ECR) Name() string {
+	return "AWS ECR"
+}
+
+func (e ECR) Credentials(ctx context.Context) (Credentials, error) {
+	token, err := aws.FetchLoginPassword(ctx, e.Region, e.AccessID, e.AccessKey)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{
+		Server:   aws.GetEcrUrl(e.AccountID, e.Region),
+		Username: "AWS",
+		Password: token,
+	}, nil
+}
+
+// Basic uses a static username and password or access token, e.g. for Docker Hub, Harbor, GitLab or GHCR.
+type Basic struct {
+	Server   string
+	Username string
+	Password string
+}
+
+func (b Basic) Name() string {
+	if b.Server == "" {
+		return "Docker Hub"
+	}
+	return b.Server
+}
+
+func (b Basic) Credentials(context.Context) (Credentials, error) {
+	return Credentials{Server: b.Server, Username: b.Username, Password: b.Password}, nil
+}
+
+// Anonymous pulls public images without logging in.
+type Anonymous struct{}
+
+func (Anonymous) Name() string {
+	return "anonymous registry access"
+}
+
+func (Anonymous) Credentials(context.Context) (Credentials, error) {
+	return Credentials{}, nil
+}
+
+// FromConfig builds the provider selected in the env config. Configs without a registry type use ECR,
+// which was the only supported registry before.
+func FromConfig(cfg fs.EnvConfig) (Provider, error) {
+	switch registryType := strings.TrimSpace(cfg[fs.RegistryType]); registryType {
+	case "", TypeECR:
+		provider := ECR{
+			Region:    strings.TrimSpace(cfg[fs.AWSRegion]),
+			AccountID: strings.TrimSpace(cfg[fs.AWSAccountID]),
+			AccessID:  strings.TrimSpace(cfg[fs.AWSAccessID]),
+			AccessKey: strings.TrimSpace(cfg[fs.AWSAccessKey]),
+		}
+		if provider.Region == "" || provider.AccountID == "" || provider.AccessID == "" || provider.AccessKey == "" {
+			return nil, errors.New("please provide AWS region, account id, access id, and access key before starting")
+		}
+		return provider, nil
+	case TypeBasic:
+		provider := Basic{
+			Server:   strings.TrimSpace(cfg[fs.RegistryServer]),
+			Username: strings.TrimSpace(cfg[fs.RegistryUsername]),
+			Password: strings.TrimSpace(cfg[fs.RegistryPassword]),
+		}
+		if provider.Username == "" || provider.Password == "" {
+			return nil, errors.New("please provide registry username and password before starting")
+		}
+		return provider, nil
+	case TypeAnonymous:
+		return Anonymous{}, nil
+	default:
+		return nil, fmt.Errorf("unknown registry type '%s'", registryType)
+	}
+}